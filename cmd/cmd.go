@@ -22,6 +22,7 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -29,10 +30,14 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
 
 	"github.com/markbates/pkger"
 	"github.com/spf13/cobra"
@@ -40,7 +45,39 @@ import (
 	"github.com/forensicanalysis/forensicworkflows/daggy"
 )
 
-func tasksFunc(workflow *daggy.Workflow, plugins map[string]daggy.Plugin, processDir string, stores []string, arguments daggy.Arguments) {
+// addRunFlags adds the flags used by tasksFunc.
+func addRunFlags(cmd *cobra.Command) {
+	cmd.Flags().String("report", "", "write a report of all tasks (json, junit)")
+	cmd.Flags().String("report-file", "", "report file (default stdout)")
+	cmd.Flags().Int("parallel", 1, "number of forensicstores processed at the same time")
+	cmd.Flags().Bool("dry-run", false, "print the execution plan without running any task")
+	cmd.Flags().Int("max-parallel", 0, "maximal number of tasks running at the same time (default max_parallel of the workflow)")
+}
+
+func tasksFunc(cmd *cobra.Command, workflow *daggy.Workflow, plugins map[string]daggy.Plugin, processDir string, stores []string, arguments daggy.Arguments) {
+	reportFormat := cmd.Flags().Lookup("report").Value.String()
+	reportFile := cmd.Flags().Lookup("report-file").Value.String()
+	parallel, err := cmd.Flags().GetInt("parallel")
+	if err != nil || parallel < 1 {
+		log.Fatal("--parallel must be a positive number")
+	}
+	maxParallel, err := cmd.Flags().GetInt("max-parallel")
+	if err != nil || maxParallel < 0 {
+		log.Fatal("--max-parallel must not be negative")
+	}
+	if maxParallel > 0 {
+		workflow.MaxParallel = maxParallel
+	}
+	switch reportFormat {
+	case "", "json", "junit":
+	default:
+		log.Fatalf("unknown report format `%s`", reportFormat)
+	}
+
+	if err := workflow.ValidateArguments(arguments); err != nil {
+		log.Fatal("invalid arguments: ", err)
+	}
+
 	workflow.SetupGraph()
 
 	// unpack scripts
@@ -50,7 +87,39 @@ func tasksFunc(workflow *daggy.Workflow, plugins map[string]daggy.Plugin, proces
 	}
 	defer os.RemoveAll(scriptDir)
 
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		for _, store := range stores {
+			storePath, err := filepath.Abs(store)
+			if err != nil {
+				log.Println("abs: ", err)
+			}
+			plan := workflow.Plan(storePath, path.Join(scriptDir, processDir), plugins, arguments)
+			if err := plan.Write(os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	report := &daggy.Report{}
+	var wg sync.WaitGroup
+	var summaryMutex sync.Mutex
+	failed := false
+	storeSlots := make(chan struct{}, parallel)
 	for _, store := range stores {
+		storeSlots <- struct{}{}
+		if ctx.Err() != nil {
+			log.Println("canceled, skipping", store)
+			summaryMutex.Lock()
+			failed = true
+			summaryMutex.Unlock()
+			<-storeSlots
+			continue
+		}
+
 		// get store path
 		storePath, err := filepath.Abs(store)
 		if err != nil {
@@ -58,11 +127,98 @@ func tasksFunc(workflow *daggy.Workflow, plugins map[string]daggy.Plugin, proces
 		}
 
 		// run workflow
-		err = workflow.Run(storePath, path.Join(scriptDir, processDir), plugins, arguments)
-		if err != nil {
-			log.Println("processing errors: ", err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-storeSlots }()
+
+			results, err := workflow.Run(ctx, storePath, path.Join(scriptDir, processDir), plugins, arguments)
+			summaryMutex.Lock()
+			printSummary(storePath, results)
+			if err != nil || hasFailed(results) {
+				failed = true
+			}
+			summaryMutex.Unlock()
+			report.Add(storePath, results, err)
+			if err != nil {
+				log.Println("processing errors: ", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reportFormat != "" {
+		if err := writeReport(report, reportFormat, reportFile); err != nil {
+			log.Println("could not write report: ", err)
+		}
+	}
+	if failed {
+		// os.Exit does not run deferred calls
+		cancel()
+		os.RemoveAll(scriptDir)
+		os.Exit(1)
+	}
+}
+
+// hasFailed returns true if any task of a workflow run failed.
+func hasFailed(results daggy.Results) bool {
+	for _, result := range results {
+		if result.Status == daggy.StatusFailed {
+			return true
 		}
 	}
+	return false
+}
+
+func writeReport(report *daggy.Report, format, file string) error {
+	if file == "" {
+		return report.Write(os.Stdout, format)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := report.Write(f, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// printSummary lists the status of all tasks of a workflow run.
+func printSummary(store string, results daggy.Results) {
+	var names []string
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Summary for", store)
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK\tSTATUS\tATTEMPTS\tREASON")
+	for _, name := range names {
+		reason := strings.SplitN(strings.TrimSpace(results[name].Reason), "\n", 2)[0]
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", name, results[name].Status, results[name].Attempts, reason)
+	}
+	w.Flush()
+}
+
+// signalContext returns a context that is canceled on SIGINT or SIGTERM, which
+// aborts running tasks and stops started containers.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Printf("received %s, canceling workflow", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
 }
 
 type PluginJSON struct {
@@ -128,19 +284,30 @@ func getArguments(cmd *cobra.Command) daggy.Arguments {
 }
 
 func unpack() (string, error) {
+	return unpackDir(pkger.Include("/scripts"))
+}
+
+// unpackWorkflows unpacks the built-in workflows.
+func unpackWorkflows() (string, error) {
+	return unpackDir(pkger.Include("/workflows"))
+}
+
+// unpackDir copies a packaged directory into the cache directory and returns
+// its path.
+func unpackDir(dir string) (string, error) {
 	cacheDir, err := os.UserCacheDir()
 	if err != nil {
 		return cacheDir, err
 	}
 
 	forensicstoreDir := filepath.Join(cacheDir, "forensicstore")
-	scriptsDir := filepath.Join(forensicstoreDir, "scripts")
+	unpackedDir := filepath.Join(forensicstoreDir, filepath.FromSlash(dir))
 
-	_ = os.RemoveAll(scriptsDir)
+	_ = os.RemoveAll(unpackedDir)
 
 	log.Printf("unpack to %s\n", forensicstoreDir)
 
-	err = pkger.Walk("/scripts", func(path string, info os.FileInfo, err error) error {
+	err = pkger.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -171,5 +338,5 @@ func unpack() (string, error) {
 		return err
 	})
 
-	return scriptsDir, err
+	return unpackedDir, err
 }
@@ -0,0 +1,65 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+)
+
+// Docker is a subcommand to manage the containers of docker tasks.
+func Docker() *cobra.Command {
+	dockerCommand := &cobra.Command{
+		Use:   "docker",
+		Short: "Manage the containers of docker tasks",
+	}
+	dockerCommand.AddCommand(Prune())
+	return dockerCommand
+}
+
+// Prune removes leftover containers of workflow runs.
+func Prune() *cobra.Command {
+	pruneCommand := &cobra.Command{
+		Use:   "prune",
+		Short: "remove leftover containers of workflow runs",
+		Run: func(cmd *cobra.Command, args []string) {
+			running, err := cmd.Flags().GetBool("running")
+			if err != nil {
+				log.Fatal(err)
+			}
+			removed, err := daggy.PruneContainers(context.Background(), running)
+			for _, id := range removed {
+				fmt.Println("removed", id)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	pruneCommand.Flags().Bool("running", false, "remove running containers as well")
+	return pruneCommand
+}
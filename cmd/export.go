@@ -71,11 +71,12 @@ func Export() *cobra.Command {
 			}
 
 			arguments := getArguments(cmd)
-			tasksFunc(workflow, export.Plugins, "export", args, arguments)
+			tasksFunc(cmd, workflow, export.Plugins, "export", args, arguments)
 		},
 	}
 	exportCommand.PersistentFlags().String("file", "", "export file")
 	exportCommand.PersistentFlags().String("format", "", "export format")
+	addRunFlags(exportCommand)
 	exportCommand.AddCommand(ListExports())
 	exportCommand.FParseErrWhitelist = cobra.FParseErrWhitelist{UnknownFlags: true}
 	return exportCommand
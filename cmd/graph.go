@@ -0,0 +1,118 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+)
+
+// Graph is a subcommand to draw the task graph of a workflow.
+func Graph() *cobra.Command {
+	graphCommand := &cobra.Command{
+		Use:   "graph",
+		Short: "Print the task graph of a workflow as dot or mermaid",
+		Args: func(cmd *cobra.Command, args []string) error {
+			return cmd.MarkFlagRequired("workflow")
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			workflowFile, err := workflowPath(cmd.Flags().Lookup("workflow").Value.String())
+			if err != nil {
+				log.Fatal(err)
+			}
+			format := cmd.Flags().Lookup("format").Value.String()
+			journalStore := cmd.Flags().Lookup("journal").Value.String()
+			reportFile := cmd.Flags().Lookup("report-file").Value.String()
+			store := cmd.Flags().Lookup("store").Value.String()
+
+			workflow, err := daggy.Parse(workflowFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			workflow.SetupGraph()
+
+			var results daggy.Results
+			switch {
+			case journalStore != "" && reportFile != "":
+				log.Fatal("--journal and --report-file cannot be used together")
+			case journalStore != "":
+				journal, err := daggy.OpenJournal(journalStore)
+				if err != nil {
+					log.Fatal(err)
+				}
+				results = journal.Results()
+			case reportFile != "":
+				results, err = reportResults(reportFile, store)
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+
+			if err := workflow.WriteGraph(os.Stdout, format, results); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	graphCommand.Flags().String("workflow", "", "workflow definition file or builtin:<name>")
+	graphCommand.Flags().String("format", "dot", "graph format (dot, mermaid)")
+	graphCommand.Flags().String("journal", "", "forensicstore whose journal is used to color tasks by status")
+	graphCommand.Flags().String("report-file", "", "json report used to color tasks by status")
+	graphCommand.Flags().String("store", "", "forensicstore in the report (default the only one)")
+	return graphCommand
+}
+
+// reportResults returns the results of a store from a json report.
+func reportResults(reportFile, store string) (daggy.Results, error) {
+	f, err := os.Open(reportFile) // #nosec
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	report, err := daggy.ReadReport(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not read report %s: %s", reportFile, err)
+	}
+
+	if store == "" {
+		if len(report.Stores) != 1 {
+			return nil, errors.New("report contains several forensicstores, select one with --store")
+		}
+		return report.Stores[0].Results(), nil
+	}
+	storePath, err := filepath.Abs(store)
+	if err != nil {
+		return nil, err
+	}
+	for _, storeReport := range report.Stores {
+		if storeReport.Store == store || storeReport.Store == storePath {
+			return storeReport.Results(), nil
+		}
+	}
+	return nil, fmt.Errorf("forensicstore %s not found in report", store)
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+)
+
+// Images is a subcommand to transfer the images of a workflow to hosts
+// without registry access.
+func Images() *cobra.Command {
+	imagesCommand := &cobra.Command{
+		Use:   "images",
+		Short: "Save and load the docker images of a workflow",
+	}
+	imagesCommand.AddCommand(SaveImages(), LoadImages())
+	return imagesCommand
+}
+
+// SaveImages writes all images of a workflow into a tar archive.
+func SaveImages() *cobra.Command {
+	saveCommand := &cobra.Command{
+		Use:   "save",
+		Short: "save all images of a workflow into a tar archive",
+		Args: func(cmd *cobra.Command, args []string) error {
+			return cmd.MarkFlagRequired("workflow")
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			workflowFile, err := workflowPath(cmd.Flags().Lookup("workflow").Value.String())
+			if err != nil {
+				log.Fatal(err)
+			}
+			workflow, err := daggy.Parse(workflowFile)
+			if err != nil {
+				log.Fatal("parsing failed: ", err)
+			}
+
+			scriptDir, err := unpack()
+			if err != nil {
+				log.Fatal("unpacking error: ", err)
+			}
+			images, err := workflow.Images(filepath.Join(scriptDir, "process"))
+			os.RemoveAll(scriptDir)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if len(images) == 0 {
+				log.Fatal("workflow does not use any image")
+			}
+
+			file := cmd.Flags().Lookup("file").Value.String()
+			f, err := os.Create(file)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := daggy.SaveImages(context.Background(), images, f); err != nil {
+				f.Close()
+				log.Fatal(err)
+			}
+			if err := f.Close(); err != nil {
+				log.Fatal(err)
+			}
+			for _, image := range images {
+				fmt.Println("saved", image)
+			}
+		},
+	}
+	saveCommand.Flags().String("workflow", "", "workflow definition file or builtin:<name>")
+	saveCommand.Flags().String("file", "images.tar", "tar archive")
+	return saveCommand
+}
+
+// LoadImages loads the images of a tar archive written by images save.
+func LoadImages() *cobra.Command {
+	loadCommand := &cobra.Command{
+		Use:   "load",
+		Short: "load images from a tar archive",
+		Run: func(cmd *cobra.Command, args []string) {
+			f, err := os.Open(cmd.Flags().Lookup("file").Value.String())
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			if err := daggy.LoadImages(context.Background(), f); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	loadCommand.Flags().String("file", "images.tar", "tar archive")
+	return loadCommand
+}
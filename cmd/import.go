@@ -70,11 +70,12 @@ func Import() *cobra.Command {
 			}
 
 			arguments := getArguments(cmd)
-			tasksFunc(workflow, imports.Plugins, "imports", args, arguments)
+			tasksFunc(cmd, workflow, imports.Plugins, "imports", args, arguments)
 		},
 	}
 	importCommand.PersistentFlags().String("file", "", "imported file")
 	importCommand.PersistentFlags().String("format", "", "imported format")
+	addRunFlags(importCommand)
 	importCommand.AddCommand(ListImports())
 	importCommand.FParseErrWhitelist = cobra.FParseErrWhitelist{UnknownFlags: true}
 	return importCommand
@@ -52,20 +52,39 @@ Those tasks can be defined to be run on the system itself or in a containerized
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			// parse workflow yaml
-			workflowFile := cmd.Flags().Lookup("workflow").Value.String()
-			if _, err := os.Stat(workflowFile); os.IsNotExist(err) {
-				log.Fatal(errors.Wrap(os.ErrNotExist, workflowFile))
+			workflowFile, err := workflowPath(cmd.Flags().Lookup("workflow").Value.String())
+			if err != nil {
+				log.Fatal(err)
 			}
 			workflow, err := daggy.Parse(workflowFile)
 			if err != nil {
 				log.Fatal("parsing failed: ", err)
 			}
+			workflow.Resume, err = cmd.Flags().GetBool("resume")
+			if err != nil {
+				log.Fatal(err)
+			}
+			workflow.NoCache, err = cmd.Flags().GetBool("no-cache")
+			if err != nil {
+				log.Fatal(err)
+			}
+			switch pull := cmd.Flags().Lookup("pull").Value.String(); pull {
+			case "":
+			case daggy.PullAlways, daggy.PullIfNotPresent, daggy.PullNever:
+				workflow.Container.Pull = pull
+			default:
+				log.Fatalf("unknown pull policy `%s`, use always, if-not-present or never", pull)
+			}
 
 			arguments := getArguments(cmd)
-			tasksFunc(workflow, process.Plugins, "process", args, arguments)
+			tasksFunc(cmd, workflow, process.Plugins, "process", args, arguments)
 		},
 	}
-	processCommand.Flags().String("workflow", "", "workflow definition file")
+	processCommand.Flags().String("workflow", "", "workflow definition file or builtin:<name>")
+	processCommand.Flags().Bool("resume", false, "skip tasks that succeeded before with the same inputs")
+	processCommand.Flags().Bool("no-cache", false, "run tasks even if their inputs did not change")
+	processCommand.Flags().String("pull", "", "default pull policy for images: always, if-not-present or never")
+	addRunFlags(processCommand)
 	processCommand.AddCommand(ListProcess())
 	return processCommand
 }
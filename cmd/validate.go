@@ -0,0 +1,72 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+	"github.com/forensicanalysis/forensicworkflows/plugins/process"
+)
+
+// Validate is a subcommand to check a workflow definition without running it.
+func Validate() *cobra.Command {
+	validateCommand := &cobra.Command{
+		Use:   "validate",
+		Short: "Check a workflow definition for errors",
+		Args: func(cmd *cobra.Command, args []string) error {
+			return cmd.MarkFlagRequired("workflow")
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			workflowFile, err := workflowPath(cmd.Flags().Lookup("workflow").Value.String())
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			workflow, err := daggy.Parse(workflowFile)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			scriptDir, err := unpack()
+			if err != nil {
+				log.Fatal("unpacking error: ", err)
+			}
+			defer os.RemoveAll(scriptDir)
+
+			if err := workflow.Validate(process.Plugins, filepath.Join(scriptDir, "process")); err != nil {
+				fmt.Println(err)
+				os.RemoveAll(scriptDir)
+				os.Exit(1)
+			}
+			fmt.Println(workflowFile, "is valid")
+		},
+	}
+	validateCommand.Flags().String("workflow", "", "workflow definition file or builtin:<name>")
+	return validateCommand
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+)
+
+// builtinPrefix selects a built-in workflow by name, e.g. builtin:windows-triage.
+const builtinPrefix = "builtin:"
+
+// Workflows is a subcommand to manage the built-in workflows.
+func Workflows() *cobra.Command {
+	workflowsCommand := &cobra.Command{
+		Use:   "workflows",
+		Short: "Manage built-in workflows",
+	}
+	workflowsCommand.AddCommand(ListWorkflows())
+	return workflowsCommand
+}
+
+// ListWorkflows prints the names and descriptions of the built-in workflows.
+func ListWorkflows() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "list built-in workflows",
+		Run: func(cmd *cobra.Command, args []string) {
+			workflowDir, err := unpackWorkflows()
+			if err != nil {
+				log.Fatal(err)
+			}
+			names, err := builtinWorkflows(workflowDir)
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, name := range names {
+				workflow, err := daggy.Parse(filepath.Join(workflowDir, name+".yml"))
+				if err != nil {
+					log.Fatal(err)
+				}
+				if workflow.Description != "" {
+					name += ":"
+				}
+				fmt.Printf("%-20s %s\n", name, workflow.Description)
+			}
+		},
+	}
+}
+
+// builtinWorkflows returns the names of the workflows in workflowDir.
+func builtinWorkflows(workflowDir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(workflowDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, info := range infos {
+		if !info.IsDir() && filepath.Ext(info.Name()) == ".yml" {
+			names = append(names, strings.TrimSuffix(info.Name(), ".yml"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// workflowPath returns the path of a workflow file. Names with the prefix
+// builtin: are resolved to the unpacked built-in workflows.
+func workflowPath(workflowFile string) (string, error) {
+	if strings.HasPrefix(workflowFile, builtinPrefix) {
+		name := strings.TrimPrefix(workflowFile, builtinPrefix)
+		workflowDir, err := unpackWorkflows()
+		if err != nil {
+			return "", errors.Wrap(err, "unpacking error")
+		}
+		names, err := builtinWorkflows(workflowDir)
+		if err != nil {
+			return "", err
+		}
+		i := sort.SearchStrings(names, name)
+		if i == len(names) || names[i] != name {
+			return "", fmt.Errorf("unknown built-in workflow `%s`, choose one of %s", name, strings.Join(names, ", "))
+		}
+		return filepath.Join(workflowDir, name+".yml"), nil
+	}
+	if _, err := os.Stat(workflowFile); os.IsNotExist(err) {
+		return "", errors.Wrap(os.ErrNotExist, workflowFile)
+	}
+	return workflowFile, nil
+}
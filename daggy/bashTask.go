@@ -23,35 +23,78 @@ package daggy
 
 import (
 	"bytes"
-	"errors"
+	"context"
 	"fmt"
 	"io"
 	"log"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"syscall"
 )
 
-func bash(command string, arguments Arguments, filter Filter, workflow *Workflow) (err error) {
-	command = filepath.ToSlash(command)
+// An ExitError is returned if a command exits with a non-zero status.
+type ExitError struct {
+	Code   int
+	Stderr string
+}
 
-	var stdout, stderr bytes.Buffer
+func (e *ExitError) Error() string {
+	stderr := strings.TrimSpace(e.Stderr)
+	if stderr == "" {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return fmt.Sprintf("exit status %d: %s", e.Code, stderr)
+}
 
-	commandArgs := append([]string{"-c"}, command)
-	commandArgs = append(commandArgs, workflow.Arguments.toCommandline()...)
+// bashArgs returns the arguments for sh to run a command.
+func bashArgs(command string, arguments Arguments, filter Filter, r *run) []string {
+	commandArgs := append([]string{"-c"}, filepath.ToSlash(command))
+	commandArgs = append(commandArgs, r.arguments.toCommandline()...)
 	commandArgs = append(commandArgs, arguments.toCommandline()...)
-	commandArgs = append(commandArgs, filter.toCommandline()...)
-	cmd := exec.Command("sh", commandArgs...) // #nosec
-	cmd.Dir = workflow.workingDir
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	return append(commandArgs, filter.toCommandline()...)
+}
+
+// bash runs a command with sh. The env is added to the environment of the
+// command, so secrets do not show up in its arguments.
+func bash(ctx context.Context, command string, arguments Arguments, filter Filter, env []string, r *run, out *output) (err error) {
+	command = filepath.ToSlash(command)
 
-	err = cmd.Run()
+	var stdout bytes.Buffer
+	var stderr tailBuffer // only the end is kept for the error
+
+	cmd := exec.Command("sh", bashArgs(command, arguments, filter, r)...) // #nosec
+	cmd.Dir = r.workingDir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = io.MultiWriter(&stdout, &out.stdout)
+	cmd.Stderr = io.MultiWriter(&stderr, &out.stderr)
+	setProcessGroup(cmd)
+
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("command `%s` failed", command)
+	}
+
+	// kill the command and all its children if the context is canceled
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-done:
+		}
+	}()
+	err = cmd.Wait()
+	close(done)
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("command `%s` aborted: %s", command, ctx.Err())
+	}
 	if err != nil {
 		if exitError, ok := err.(*exec.ExitError); ok {
 			if waitStatus, ok := exitError.Sys().(syscall.WaitStatus); ok {
 				if waitStatus.ExitStatus() != 0 {
-					return errors.New(stderr.String())
+					return &ExitError{Code: waitStatus.ExitStatus(), Stderr: stderr.String()}
 				}
 			}
 		} else {
@@ -0,0 +1,69 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestExitError_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *ExitError
+		want string
+	}{
+		{"stderr", &ExitError{Code: 2, Stderr: "file not found\n"}, "exit status 2: file not found"},
+		{"empty stderr", &ExitError{Code: 3}, "exit status 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkflow_RunLongStderr(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggystderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	workflow := Workflow{Tasks: map[string]Task{
+		"noisy": {Type: "bash", Command: `head -c 200000 /dev/zero | tr '\\0' x >&2; exit 1`},
+	}}
+	workflow.SetupGraph()
+
+	results, _ := workflow.Run(context.Background(), storeDir, "", nil, Arguments{})
+	result := results["noisy"]
+	if result.ExitCode != 1 {
+		t.Fatalf("exit code = %d, want 1: %s", result.ExitCode, result.Reason)
+	}
+	if len(result.Reason) > maxOutput+len("exit status 1: ") {
+		t.Errorf("reason has %d bytes, want at most the last %d bytes of stderr", len(result.Reason), maxOutput)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/forensicanalysis/forensicstore/goflatten"
+	"github.com/forensicanalysis/forensicstore/gostore"
+)
+
+const (
+	// maxVariables is the maximum number of values in a single SQLite statement.
+	maxVariables = 999
+	// maxPending is the number of items buffered before all batches are written.
+	maxPending = 1000
+)
+
+// A BatchInserter buffers items and inserts them in batches. Each batch is
+// written with a single INSERT statement, which SQLite executes in its own
+// transaction, instead of one transaction for every item. Items of the same
+// type with the same fields are batched together, as required by
+// InsertBatch. Flush must be called after the last item is inserted.
+type BatchInserter struct {
+	ctx     context.Context
+	store   gostore.Store
+	batches map[string][]gostore.Item
+	pending int
+}
+
+// NewBatchInserter creates a BatchInserter for a store. The inserted items are
+// counted for the task of ctx.
+func NewBatchInserter(ctx context.Context, store gostore.Store) *BatchInserter {
+	return &BatchInserter{ctx: ctx, store: store, batches: map[string][]gostore.Item{}}
+}
+
+// Insert adds an item to its batch. Batches are written if they reach the
+// limits of SQLite or too many items are buffered.
+func (b *BatchInserter) Insert(item gostore.Item) error {
+	flat, err := goflatten.Flatten(item)
+	if err != nil {
+		return err
+	}
+	if _, ok := flat["uid"]; !ok {
+		flat["uid"] = nil // set by InsertBatch
+	}
+	fields := make([]string, 0, len(flat))
+	for field := range flat {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	key := fmt.Sprint(item["type"]) + "\x00" + strings.Join(fields, "\x00")
+
+	b.batches[key] = append(b.batches[key], item)
+	b.pending++
+
+	switch {
+	case (len(b.batches[key])+1)*len(flat) > maxVariables:
+		return b.flushBatch(key)
+	case b.pending >= maxPending:
+		return b.Flush()
+	}
+	return nil
+}
+
+// Flush writes all buffered items.
+func (b *BatchInserter) Flush() error {
+	keys := make([]string, 0, len(b.batches))
+	for key := range b.batches {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := b.flushBatch(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BatchInserter) flushBatch(key string) error {
+	batch := b.batches[key]
+	delete(b.batches, key)
+	b.pending -= len(batch)
+	if _, err := b.store.InsertBatch(batch); err != nil {
+		return err
+	}
+	CountItems(b.ctx, len(batch))
+	return nil
+}
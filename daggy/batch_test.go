@@ -0,0 +1,94 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/forensicanalysis/forensicstore/goforensicstore"
+	"github.com/forensicanalysis/forensicstore/gostore"
+)
+
+func TestBatchInserter(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "daggybatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	storeDir := filepath.Join(tempDir, "test.forensicstore")
+
+	store, err := goforensicstore.NewJSONLite(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemCount := &itemCount{}
+	inserter := NewBatchInserter(withItemCount(context.Background(), itemCount), store)
+	count := 2500
+	for i := 0; i < count; i++ {
+		item := gostore.Item{"type": "element", "name": fmt.Sprint(i)}
+		switch i % 3 {
+		case 1:
+			item["size"] = i
+		case 2:
+			item["type"] = "other"
+			item["attributes"] = map[string]interface{}{"created": "2020-01-02T00:00:00Z"}
+		}
+		if err := inserter.Insert(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if inserter.pending >= maxPending {
+		t.Errorf("pending = %d, want less than %d", inserter.pending, maxPending)
+	}
+	if err := inserter.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if inserter.pending != 0 || len(inserter.batches) != 0 {
+		t.Errorf("Flush() left %d items", inserter.pending)
+	}
+	if itemCount.inserted != count {
+		t.Errorf("counted %d items, want %d", itemCount.inserted, count)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := MatchItems(storeDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != count {
+		t.Fatalf("inserted %d items, want %d", len(items), count)
+	}
+	others, err := SelectItems(storeDir, "other", Filter{{"attributes.created": "prefix:2020"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(others) != count/3 {
+		t.Errorf("inserted %d other items, want %d", len(others), count/3)
+	}
+}
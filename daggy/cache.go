@@ -0,0 +1,138 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// An InputPlugin declares the item types a plugin reads, so tasks that run
+// the plugin can be cached without declaring inputs in the workflow.
+type InputPlugin interface {
+	InputTypes() []string
+}
+
+// inputTypes returns the item types a task reads, either from the inputs of
+// the task or from its plugin.
+func (r *run) inputTypes(task Task) []string {
+	if len(task.Inputs) > 0 {
+		return task.Inputs
+	}
+	if task.Type == "plugin" {
+		if plugin, ok := r.plugins[task.Command].(InputPlugin); ok {
+			return plugin.InputTypes()
+		}
+	}
+	return nil
+}
+
+// cacheKey identifies everything a task depends on: its definition, the
+// arguments and vars of the run, the digest of the plugin, script or image and
+// the items it reads. Tasks whose input types are unknown cannot be cached and
+// false is returned. for_each tasks are cached for every item.
+func (r *run) cacheKey(ctx context.Context, task Task) (string, bool) {
+	types := r.inputTypes(task)
+	if len(types) == 0 || task.ForEach != nil {
+		return "", false
+	}
+	task, err := r.resolve(task)
+	if err != nil {
+		return "", false
+	}
+	source, err := r.sourceDigest(ctx, task)
+	if err != nil {
+		return "", false
+	}
+	items, err := itemsDigest(r.workingDir, types, task.Filter)
+	if err != nil {
+		return "", false
+	}
+	b, _ := json.Marshal(struct {
+		Input  string
+		Source string
+		Items  string
+	}{inputHash(task, r.arguments, r.vars), source, items})
+	return fmt.Sprintf("%x", sha256.Sum256(b)), true
+}
+
+// sourceDigest returns the digest of the code a task runs. Built-in plugins
+// are part of the executable, scripts and dockerfiles are hashed and docker
+// tasks use the id of the image after pulling it.
+func (r *run) sourceDigest(ctx context.Context, task Task) (string, error) {
+	switch task.Type {
+	case "bash":
+		return "", nil
+	case "plugin":
+		if _, ok := r.plugins[task.Command]; ok {
+			return executableDigest()
+		}
+		scriptPath, err := findScript(r.pluginDir, strings.Split(task.Command, " ")[0])
+		if err != nil {
+			return "", err
+		}
+		return fileDigest(scriptPath)
+	case "docker":
+		return r.imageDigest(ctx, task.Image, r.containerOptions(task).container.Pull)
+	case "dockerfile":
+		return fileDigest(filepath.Join(r.pluginDir, task.Dockerfile, "Dockerfile"))
+	}
+	return "", fmt.Errorf("tasks of type %s cannot be cached", task.Type)
+}
+
+var executable struct {
+	once   sync.Once
+	digest string
+	err    error
+}
+
+func executableDigest() (string, error) {
+	executable.once.Do(func() {
+		path, err := os.Executable()
+		if err != nil {
+			executable.err = err
+			return
+		}
+		executable.digest, executable.err = fileDigest(path)
+	})
+	return executable.digest, executable.err
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path) // #nosec
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
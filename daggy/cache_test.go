@@ -0,0 +1,122 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/forensicanalysis/forensicstore/goforensicstore"
+)
+
+func insertElement(t *testing.T, storeDir, name string) {
+	store, err := goforensicstore.NewJSONLite(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Insert(map[string]interface{}{"type": "element", "name": name}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkflow_RunCache(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggycache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+	insertElement(t, storeDir, "a.pf")
+
+	// cached tasks must give back their slot
+	workflow := Workflow{MaxParallel: 1, Tasks: map[string]Task{
+		"cached": {
+			Type:    "bash",
+			Command: `echo run >> cached.txt; echo '{"count": 5}'`,
+			Inputs:  []string{"element"},
+			Filter:  Filter{{"name": "%.pf"}},
+			Outputs: map[string]Output{"count": {}},
+		},
+		"uncached": {Type: "bash", Command: "echo run >> uncached.txt"},
+	}}
+	workflow.SetupGraph()
+
+	tests := []struct {
+		name    string
+		setup   func()
+		noCache bool
+		want    Status
+		runs    int
+	}{
+		{"first run", func() {}, false, StatusSucceeded, 1},
+		{"unchanged", func() {}, false, StatusCached, 1},
+		{"other item", func() { insertElement(t, storeDir, "b.evtx") }, false, StatusCached, 1},
+		{"new input item", func() { insertElement(t, storeDir, "c.pf") }, false, StatusSucceeded, 2},
+		{"no cache", func() {}, true, StatusSucceeded, 3},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			workflow.NoCache = tt.noCache
+			results, err := workflow.Run(context.Background(), storeDir, "", nil, Arguments{})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if got := results["cached"].Status; got != tt.want {
+				t.Errorf("cached status = %s, want %s", got, tt.want)
+			}
+			if got := results["cached"].Outputs["count"]; got != "5" {
+				t.Errorf("cached output count = %s, want 5", got)
+			}
+			if got := results["uncached"].Status; got != StatusSucceeded {
+				t.Errorf("uncached status = %s, want %s", got, StatusSucceeded)
+			}
+			if got := countLines(t, filepath.Join(storeDir, "cached.txt")); got != tt.runs {
+				t.Errorf("cached task ran %d times, want %d", got, tt.runs)
+			}
+			if got := countLines(t, filepath.Join(storeDir, "uncached.txt")); got != i+1 {
+				t.Errorf("uncached task ran %d times, want %d", got, i+1)
+			}
+			journal, err := OpenJournal(storeDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key := journal.Tasks["cached"].CacheKey; (key == "") != tt.noCache {
+				t.Errorf("cache key = %q with no cache %v", key, tt.noCache)
+			}
+		})
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(b), "\n")
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"bytes"
+	"log"
+	"sync"
+)
+
+// maxOutput is the number of bytes of stdout and stderr kept for every task.
+const maxOutput = 64 * 1024
+
+// output captures the stdout, stderr and outputs of a task.
+type output struct {
+	task          string // name of the task for log prefixes
+	stdout        tailBuffer
+	stderr        tailBuffer
+	pluginOutputs Outputs // returned by an OutputPlugin
+	outputs       Outputs // declared in the workflow
+}
+
+// tailBuffer is a writer that keeps only the last maxOutput bytes.
+type tailBuffer struct {
+	mutex sync.Mutex
+	buf   []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > maxOutput {
+		b.buf = append([]byte(nil), b.buf[len(b.buf)-maxOutput:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return string(b.buf)
+}
+
+func (b *tailBuffer) reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.buf = nil
+}
+
+// lineLogger writes every complete line to the log with the task name as
+// prefix, so the output of tasks running in parallel can be told apart.
+type lineLogger struct {
+	prefix string
+	buf    []byte
+}
+
+func newLineLogger(task string) *lineLogger {
+	return &lineLogger{prefix: "[" + task + "] "}
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		l.println(l.buf[:i])
+		l.buf = l.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush writes an incomplete last line.
+func (l *lineLogger) flush() {
+	if len(l.buf) > 0 {
+		l.println(l.buf)
+		l.buf = nil
+	}
+}
+
+func (l *lineLogger) println(line []byte) {
+	log.Println(l.prefix + string(bytes.TrimRight(line, "\r")))
+}
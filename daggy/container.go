@@ -0,0 +1,189 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// Labels added to every container created for a task.
+const (
+	LabelWorkflow = "forensicworkflows.workflow"
+	LabelTask     = "forensicworkflows.task"
+	LabelStore    = "forensicworkflows.store"
+	LabelRun      = "forensicworkflows.run"
+)
+
+// Network modes of containers.
+const (
+	NetworkNone   = "none"
+	NetworkBridge = "bridge"
+)
+
+// Pull policies for the images of docker tasks.
+const (
+	PullAlways       = "always"
+	PullIfNotPresent = "if-not-present"
+	PullNever        = "never"
+)
+
+// Container configures the container of a docker or dockerfile task. Settings
+// of the workflow are the defaults for all tasks.
+type Container struct {
+	Pull          string            `yaml:"pull"`    // always (default), if-not-present or never
+	Memory        string            `yaml:"memory"`  // e.g. 512MB or 2GiB
+	CPUs          float64           `yaml:"cpus"`    // e.g. 1.5
+	Network       string            `yaml:"network"` // none or bridge
+	User          string            `yaml:"user"`
+	ReadOnlyStore *bool             `yaml:"read_only_store"`
+	Env           map[string]string `yaml:"env"`
+}
+
+// merge returns the settings of c with the unset ones taken from defaults.
+func (c Container) merge(defaults Container) Container {
+	if c.Pull == "" {
+		c.Pull = defaults.Pull
+	}
+	if c.Memory == "" {
+		c.Memory = defaults.Memory
+	}
+	if c.CPUs == 0 {
+		c.CPUs = defaults.CPUs
+	}
+	if c.Network == "" {
+		c.Network = defaults.Network
+	}
+	if c.User == "" {
+		c.User = defaults.User
+	}
+	if c.ReadOnlyStore == nil {
+		c.ReadOnlyStore = defaults.ReadOnlyStore
+	}
+	env := map[string]string{}
+	for name, value := range defaults.Env {
+		env[name] = value
+	}
+	for name, value := range c.Env {
+		env[name] = value
+	}
+	c.Env = env
+	return c
+}
+
+func (c Container) memoryBytes() int64 {
+	memory, _ := parseNumber(c.Memory)
+	return int64(memory)
+}
+
+func (c Container) readOnlyStore() bool {
+	return c.ReadOnlyStore != nil && *c.ReadOnlyStore
+}
+
+// envList returns the environment in the KEY=value form used by docker.
+func (c Container) envList() []string {
+	var env []string
+	for _, name := range sortedKeys(c.Env) {
+		env = append(env, name+"="+c.Env[name])
+	}
+	return env
+}
+
+// containerOptions configure the container of a docker or dockerfile task.
+type containerOptions struct {
+	keep      bool // keep the container after the task for debugging
+	container Container
+	secrets   map[string]string // passed as environment variables
+}
+
+func (r *run) containerOptions(task Task) containerOptions {
+	opts := containerOptions{keep: task.KeepContainer, container: task.Container.merge(r.workflow.Container), secrets: r.taskSecrets(task)}
+	if opts.container.Pull == "" {
+		opts.container.Pull = PullAlways
+	}
+	return opts
+}
+
+// containerLabels identify the workflow run a container belongs to, so
+// leftover containers can be removed by PruneContainers.
+func (r *run) containerLabels(taskName string) map[string]string {
+	return map[string]string{
+		LabelWorkflow: r.workflow.name(),
+		LabelTask:     taskName,
+		LabelStore:    r.workingDir,
+		LabelRun:      r.id,
+	}
+}
+
+// name returns the name of the workflow file without extension.
+func (workflow *Workflow) name() string {
+	if workflow.source == nil {
+		return ""
+	}
+	base := filepath.Base(workflow.source.file)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// newRunID returns a random id for a workflow run.
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Println("could not create run id:", err)
+	}
+	return fmt.Sprintf("%x", b)
+}
+
+// PruneContainers removes the containers of workflow runs that were not
+// removed, e.g. because forensicworkflows crashed or keep_container was set.
+// Running containers are only removed if running is true. The ids of the
+// removed containers are returned.
+func PruneContainers(ctx context.Context, running bool) ([]string, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: filters.NewArgs(filters.Arg("label", LabelRun))})
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, c := range containers {
+		if c.State == "running" && !running {
+			continue
+		}
+		if err := cli.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{Force: true}); err != nil {
+			return removed, err
+		}
+		removed = append(removed, c.ID)
+	}
+	return removed, nil
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_containerLabels(t *testing.T) {
+	workflowFile := writeWorkflow(t, "tasks:\n  a:\n    type: docker\n    image: alpine\n    keep_container: true\n")
+	defer os.RemoveAll(filepath.Dir(workflowFile))
+	workflow, err := Parse(workflowFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &run{workflow: workflow, workingDir: "/store", id: newRunID()}
+	want := map[string]string{LabelWorkflow: "workflow", LabelTask: "sub/a", LabelStore: "/store", LabelRun: r.id}
+	if got := r.containerLabels("sub/a"); !reflect.DeepEqual(got, want) {
+		t.Errorf("containerLabels() = %v, want %v", got, want)
+	}
+	if len(r.id) != 16 || r.id == newRunID() {
+		t.Errorf("newRunID() = %s, want 16 random hex digits", r.id)
+	}
+	if opts := r.containerOptions(workflow.Tasks["a"]); !opts.keep || opts.container.Pull != PullAlways {
+		t.Errorf("containerOptions() = %+v, want keep and pull always", opts)
+	}
+	if got := dockerRunCommand("alpine", "true", nil, nil, r.containerOptions(workflow.Tasks["a"]), r); got[2] == "--rm" {
+		t.Errorf("dockerRunCommand() = %v, want no --rm", got)
+	}
+}
+
+func TestContainer_merge(t *testing.T) {
+	readOnly, writable := true, false
+	defaults := Container{Memory: "1GB", Network: NetworkNone, ReadOnlyStore: &readOnly, Env: map[string]string{"TZ": "UTC", "LANG": "C"}}
+	workflow := &Workflow{Container: defaults}
+	r := &run{workflow: workflow, workingDir: "/store", pluginDir: "/plugins"}
+
+	task := Task{Type: "docker", Image: "alpine", Container: Container{CPUs: 2, ReadOnlyStore: &writable, Env: map[string]string{"LANG": "en_US"}}}
+	got := r.containerOptions(task).container
+	want := Container{Pull: PullAlways, Memory: "1GB", CPUs: 2, Network: NetworkNone, ReadOnlyStore: &writable, Env: map[string]string{"TZ": "UTC", "LANG": "en_US"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merge() = %+v, want %+v", got, want)
+	}
+
+	task.ReadOnlyStore = nil
+	wantCommand := []string{"docker", "run", "--rm", "--memory", "1000000000", "--cpus", "2", "--network", "none", "-e", "LANG=en_US", "-e", "TZ=UTC", "-v", "/store:/store:ro", "-v", "/plugins:/plugins:ro", "-w", "/store", "alpine", "true"}
+	if got := dockerRunCommand("alpine", "true", nil, nil, r.containerOptions(task), r); !reflect.DeepEqual(got, wantCommand) {
+		t.Errorf("dockerRunCommand() = %v, want %v", got, wantCommand)
+	}
+}
+
+func Test_dockerArgsTransit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "daggytransit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	existing := filepath.Join(dir, "import.json")
+	if err := ioutil.WriteFile(existing, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &run{workingDir: "/store", pluginDir: "/plugins"}
+	tests := []struct {
+		name         string
+		file         string
+		wantReadOnly bool
+	}{
+		{"import", existing, true},
+		{"export", filepath.Join(dir, "export.json"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mounts := dockerArgs("true", Arguments{"file": tt.file}, nil, false, r)
+			if !mounts[1].ReadOnly {
+				t.Error("/plugins is writable")
+			}
+			if transit := mounts[2]; transit.Target != "/transit" || transit.ReadOnly != tt.wantReadOnly {
+				t.Errorf("transit mount = %+v, want read only %v", transit, tt.wantReadOnly)
+			}
+		})
+	}
+}
@@ -23,37 +23,39 @@ package daggy
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
-func docker(image, command string, arguments Arguments, filter Filter, pull bool, workflow *Workflow) error {
-	ctx := context.Background()
+func docker(ctx context.Context, image, command string, arguments Arguments, filter Filter, opts containerOptions, r *run, out *output) error {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		return err
 	}
 
-	if pull {
-		err = pullImage(ctx, cli, workflow, image)
-		if err != nil {
-			return err
-		}
+	// the image id is used, so the container runs the image of the cache key
+	imageID, err := r.imageID(ctx, cli, image, opts.container.Pull)
+	if err != nil {
+		return err
 	}
 
 	// create directory if not exists
-	_, err = os.Open(workflow.workingDir)
+	_, err = os.Open(r.workingDir)
 	if os.IsNotExist(err) {
-		log.Println("creating directory", workflow.workingDir)
-		err = os.MkdirAll(workflow.workingDir, os.ModePerm)
+		log.Println("creating directory", r.workingDir)
+		err = os.MkdirAll(r.workingDir, os.ModePerm)
 		if err != nil {
 			return err
 		}
@@ -61,69 +63,145 @@ func docker(image, command string, arguments Arguments, filter Filter, pull bool
 		return err
 	}
 
-	if workflow.workingDir[1] == ':' {
-		workflow.workingDir = "/" + strings.ToLower(string(workflow.workingDir[0])) + filepath.ToSlash(workflow.workingDir[2:])
-	}
-
-	if workflow.pluginDir[1] == ':' {
-		workflow.pluginDir = "/" + strings.ToLower(string(workflow.pluginDir[0])) + filepath.ToSlash(workflow.pluginDir[2:])
-	}
-
-	resp, err := createContainer(ctx, cli, workflow, image, command, arguments, filter)
+	resp, err := createContainer(ctx, cli, r, out.task, imageID, command, arguments, filter, opts)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		// aborted containers are removed in any case
+		if !opts.keep || ctx.Err() != nil {
+			removeContainer(cli, resp.ID)
+		} else {
+			log.Println("keep container", resp.ID)
+		}
+	}()
 
 	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
 		return err
 	}
 
+	// stream the logs while the container runs
+	logs, err := cli.ContainerLogs(ctx, resp.ID, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Follow: true})
+	if err != nil {
+		return err
+	}
+	// only the end of stderr is kept for the error
+	var stderr tailBuffer
+	copied := make(chan error, 1)
+	go func() {
+		stdoutLog, stderrLog := newLineLogger(out.task), newLineLogger(out.task)
+		_, err := stdcopy.StdCopy(io.MultiWriter(&out.stdout, stdoutLog), io.MultiWriter(&out.stderr, &stderr, stderrLog), logs)
+		stdoutLog.flush()
+		stderrLog.flush()
+		logs.Close()
+		copied <- err
+	}()
+
+	var status container.ContainerWaitOKBody
 	statusChannel, errChannel := cli.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
 	select {
 	case err := <-errChannel:
+		if ctx.Err() != nil {
+			return fmt.Errorf("container %s aborted: %s", image, ctx.Err())
+		}
 		if err != nil {
 			return err
 		}
-	case <-statusChannel:
+	case status = <-statusChannel:
 	}
 
-	out, err := cli.ContainerLogs(ctx, resp.ID, types.ContainerLogsOptions{ShowStdout: true})
-	if err != nil {
+	if err := <-copied; err != nil {
 		return err
 	}
+	if status.StatusCode != 0 {
+		return &ExitError{Code: int(status.StatusCode), Stderr: stderr.String()}
+	}
+	if status.Error != nil && status.Error.Message != "" {
+		return fmt.Errorf("container %s: %s", image, status.Error.Message)
+	}
+	return nil
+}
 
-	// stdcopy.StdCopy(os.Stdout, os.Stderr, out)
-	// _, err = ioutil.ReadAll(out)
-	_, err = io.Copy(log.Writer(), out)
-	return err
+// imageDigest returns the id of a docker image. The image is pulled according
+// to the pull policy first, so a changed remote image changes the digest.
+func (r *run) imageDigest(ctx context.Context, image, policy string) (string, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return "", err
+	}
+	defer cli.Close()
+	return r.imageID(ctx, cli, image, policy)
 }
 
-func createContainer(ctx context.Context, cli *client.Client, workflow *Workflow, image, command string, arguments Arguments, filter Filter) (container.ContainerCreateCreatedBody, error) {
+// pulledImages holds the ids of the images pulled in a workflow run, so every
+// image is pulled only once per run.
+type pulledImages struct {
+	mutex sync.Mutex
+	ids   map[string]string
+}
+
+// imageID pulls an image according to the pull policy and returns its id.
+// Images that are never pulled, e.g. images built from dockerfiles, are
+// looked up every time.
+func (r *run) imageID(ctx context.Context, cli *client.Client, image, policy string) (string, error) {
+	cached := r.images != nil && policy != PullNever
+	if cached {
+		r.images.mutex.Lock()
+		id, ok := r.images.ids[image]
+		r.images.mutex.Unlock()
+		if ok {
+			return id, nil
+		}
+	}
+	if err := ensureImage(ctx, cli, image, policy); err != nil {
+		return "", err
+	}
+	inspect, _, err := cli.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return "", err
+	}
+	if cached {
+		r.images.mutex.Lock()
+		r.images.ids[image] = inspect.ID
+		r.images.mutex.Unlock()
+	}
+	return inspect.ID, nil
+}
+
+// dockerArgs returns the command and the mounts of a container.
+func dockerArgs(command string, arguments Arguments, filter Filter, readOnlyStore bool, r *run) ([]string, []mount.Mount) {
 	mounts := []mount.Mount{
-		{Type: mount.TypeBind, Source: workflow.workingDir, Target: "/store"},
-		{Type: mount.TypeBind, Source: workflow.pluginDir, Target: "/plugins"},
+		{Type: mount.TypeBind, Source: dockerPath(r.workingDir), Target: "/store", ReadOnly: readOnlyStore},
+		{Type: mount.TypeBind, Source: dockerPath(r.pluginDir), Target: "/plugins", ReadOnly: true},
 	}
-	cmd := strings.Split(command, " ")
-	cmd = append(cmd, workflow.Arguments.toCommandline()...) // TODO: remove "file"
-	cmd = append(cmd, arguments.toCommandline()...)          // TODO: remove "file"
+	cmd := shellSplit(command)
+	cmd = append(cmd, r.arguments.toCommandline()...) // TODO: remove "file"
+	cmd = append(cmd, arguments.toCommandline()...)   // TODO: remove "file"
 	cmd = append(cmd, filter.toCommandline()...)
 
-	// add transit dir if import or export
+	// add transit dir if import or export, an existing file is only read
 	transitPath := arguments.Get("file")
 	if transitPath != "" {
 		transitDir, transitFile := filepath.Split(transitPath)
-		if transitDir[1] == ':' {
-			transitDir = "/" + strings.ToLower(string(transitDir[0])) + filepath.ToSlash(transitDir[2:])
-		}
-		mounts = append(mounts, mount.Mount{Type: mount.TypeBind, Source: transitDir, Target: "/transit"})
+		_, err := os.Stat(transitPath)
+		mounts = append(mounts, mount.Mount{Type: mount.TypeBind, Source: dockerPath(transitDir), Target: "/transit", ReadOnly: err == nil})
 		cmd = append(cmd, "--file", transitFile)
 	}
+	return cmd, mounts
+}
 
-	log.Printf("workingDir: %s,pluginDir: %s, cmd: %s\n", workflow.workingDir, workflow.pluginDir, cmd)
+func createContainer(ctx context.Context, cli *client.Client, r *run, taskName, image, command string, arguments Arguments, filter Filter, opts containerOptions) (container.ContainerCreateCreatedBody, error) {
+	c := opts.container
+	cmd, mounts := dockerArgs(command, arguments, filter, c.readOnlyStore(), r)
+	log.Printf("workingDir: %s,pluginDir: %s, cmd: %s\n", mounts[0].Source, mounts[1].Source, cmd)
 	resp, err := cli.ContainerCreate(
 		ctx,
-		&container.Config{Image: image, Cmd: cmd, Tty: true, WorkingDir: "/store"},
-		&container.HostConfig{Mounts: mounts},
+		&container.Config{Image: image, Cmd: cmd, WorkingDir: "/store", Labels: r.containerLabels(taskName), User: c.User, Env: append(c.envList(), secretEnvList(opts.secrets)...)},
+		&container.HostConfig{
+			Mounts:      mounts,
+			NetworkMode: container.NetworkMode(c.Network),
+			Resources:   container.Resources{Memory: c.memoryBytes(), NanoCPUs: int64(c.CPUs * 1e9)},
+		},
 		nil,
 		"",
 	)
@@ -133,19 +211,55 @@ func createContainer(ctx context.Context, cli *client.Client, workflow *Workflow
 	return resp, nil
 }
 
-func pullImage(ctx context.Context, cli *client.Client, workflow *Workflow, image string) error {
-	var auth types.AuthConfig
-	auth.Username = workflow.Arguments.Get("docker-user")
-	auth.Password = workflow.Arguments.Get("docker-password")
-	auth.ServerAddress = workflow.Arguments.Get("docker-server")
+// dockerPath converts a Windows path like C:\store to the /c/store form used
+// for docker mounts.
+func dockerPath(p string) string {
+	if len(p) < 2 || p[1] != ':' {
+		return p
+	}
+	return "/" + strings.ToLower(string(p[0])) + filepath.ToSlash(p[2:])
+}
+
+// removeContainer stops and removes a container. It does not use the task
+// context, as it is called when this context is already canceled.
+func removeContainer(cli *client.Client, id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	timeout := 10 * time.Second
+	if err := cli.ContainerStop(ctx, id, &timeout); err != nil {
+		log.Println("could not stop container", id, err)
+	}
+	if err := cli.ContainerRemove(ctx, id, types.ContainerRemoveOptions{Force: true}); err != nil {
+		log.Println("could not remove container", id, err)
+	}
+}
+
+// ensureImage pulls the image according to the pull policy.
+func ensureImage(ctx context.Context, cli *client.Client, image, policy string) error {
+	if policy == PullAlways {
+		return pullImage(ctx, cli, image)
+	}
+	_, _, err := cli.ImageInspectWithRaw(ctx, image)
+	switch {
+	case err == nil:
+		return nil
+	case !client.IsErrNotFound(err):
+		return err
+	case policy == PullNever:
+		return fmt.Errorf("image %s is not present and pull is %s", image, PullNever)
+	}
+	return pullImage(ctx, cli, image)
+}
 
-	body, err := cli.RegistryLogin(ctx, auth)
+// pullImage pulls an image with the credentials of the docker config.
+func pullImage(ctx context.Context, cli *client.Client, image string) error {
+	auth, err := registryAuth(ctx, image)
 	if err != nil {
 		return err
 	}
-	log.Println("login", body)
 
-	reader, err := cli.ImagePull(ctx, image, types.ImagePullOptions{})
+	reader, err := cli.ImagePull(ctx, image, types.ImagePullOptions{RegistryAuth: auth})
 	if err != nil {
 		return err
 	}
@@ -34,8 +34,7 @@ import (
 	"github.com/pkg/errors"
 )
 
-func dockerfile(dockerfile string, arguments Arguments, filter Filter, workflow *Workflow) error {
-	ctx := context.Background()
+func dockerfile(ctx context.Context, dockerfile string, arguments Arguments, filter Filter, opts containerOptions, r *run, out *output) error {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		return err
@@ -45,32 +44,39 @@ func dockerfile(dockerfile string, arguments Arguments, filter Filter, workflow
 	tw := tar.NewWriter(buf)
 	defer tw.Close()
 
-	err = tarFolder(filepath.Join(workflow.pluginDir, dockerfile), tw)
+	err = tarFolder(filepath.Join(r.pluginDir, dockerfile), tw)
 	if err != nil {
 		return err
 	}
 	dockerFileTarReader := bytes.NewReader(buf.Bytes())
 
-	var authConfigs map[string]types.AuthConfig
-
-	var authConfig types.AuthConfig
-	authConfig.Username = workflow.Arguments.Get("docker-user")
-	authConfig.Password = workflow.Arguments.Get("docker-password")
-	if server := workflow.Arguments.Get("docker-server"); server != "" {
-		authConfig.ServerAddress = server
-		authConfigs = map[string]types.AuthConfig{
-			authConfig.ServerAddress: authConfig,
+	// base images are only pulled with the pull policy always
+	policy := opts.container.Pull
+	if policy != PullAlways {
+		images, err := baseImages(filepath.Join(r.pluginDir, dockerfile, "Dockerfile"))
+		if err != nil {
+			return err
+		}
+		for _, image := range images {
+			if err := ensureImage(ctx, cli, image, policy); err != nil {
+				return err
+			}
 		}
 	}
 
+	authConfigs, err := buildAuthConfigs(ctx)
+	if err != nil {
+		return err
+	}
+
 	opt := types.ImageBuildOptions{
 		SuppressOutput: false,
 		Remove:         true,
 		ForceRemove:    true,
-		PullParent:     true,
+		PullParent:     policy == PullAlways,
 		Dockerfile:     "Dockerfile",
 		Context:        dockerFileTarReader,
-		Tags:           []string{"plugin" + dockerfile},
+		Tags:           []string{dockerfileImage(dockerfile)},
 		AuthConfigs:    authConfigs,
 	}
 	imageBuildResponse, err := cli.ImageBuild(ctx, dockerFileTarReader, opt)
@@ -84,5 +90,12 @@ func dockerfile(dockerfile string, arguments Arguments, filter Filter, workflow
 		return errors.Wrap(err, "unable to read image build response")
 	}
 
-	return docker("plugin"+dockerfile, "", arguments, filter, false, workflow)
+	// the image was built, not pulled
+	opts.container.Pull = PullNever
+	return docker(ctx, dockerfileImage(dockerfile), "", arguments, filter, opts, r, out)
+}
+
+// dockerfileImage returns the name of the image built from a dockerfile.
+func dockerfileImage(dockerfile string) string {
+	return "plugin" + dockerfile
 }
@@ -0,0 +1,443 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/forensicanalysis/forensicstore/gostore"
+)
+
+// A Filter is a list of conditions that should be used for a Task. An item
+// matches the filter if it matches any condition and it matches a condition
+// if all attributes of the condition match. Attributes can be dotted paths
+// into nested objects, e.g. attributes.created. A value can be prefixed by an
+// operator:
+//
+//	eq:value            equals value
+//	prefix:value        starts with value
+//	suffix:value        ends with value
+//	contains:value      contains value
+//	regex:pattern       matches the regular expression
+//	gt:, ge:, lt:, le:  compares numbers, sizes like 1MB or 4KiB, or times
+//	                    like 2020-01-02 or 2020-01-02T15:04:05Z
+//	in:a|b|c            equals any of the values
+//
+// A leading ! negates the operator, e.g. !contains:System32, where !value is
+// short for !contains:value. Values without operator keep their former
+// meaning: Match tests if the attribute contains the value, while
+// SelectIterator and script plugins use the value as a LIKE pattern.
+type Filter []map[string]string
+
+// Filter operators.
+const (
+	opPlain    = ""
+	opEq       = "eq"
+	opPrefix   = "prefix"
+	opSuffix   = "suffix"
+	opContains = "contains"
+	opRegex    = "regex"
+	opGt       = "gt"
+	opGe       = "ge"
+	opLt       = "lt"
+	opLe       = "le"
+	opIn       = "in"
+)
+
+var operatorPattern = regexp.MustCompile(`^(eq|prefix|suffix|contains|regex|gt|ge|lt|le|in):`)
+
+// A condition is a parsed filter expression of a single attribute.
+type condition struct {
+	attribute string
+	negate    bool
+	operator  string
+	value     string
+	values    []string
+	regex     *regexp.Regexp
+	number    *float64
+	time      *time.Time
+}
+
+// filterCacheSize is the number of parsed conditions and LIKE patterns kept.
+const filterCacheSize = 1024
+
+// conditionCache avoids parsing the same expressions for every item. It is
+// bounded, as for_each tasks add a condition for every item.
+var conditionCache = newLRUCache(filterCacheSize)
+
+func parseCondition(attribute, expression string) (*condition, error) {
+	key := attribute + "\x00" + expression
+	if c, ok := conditionCache.load(key); ok {
+		return c.(*condition), nil
+	}
+
+	c := &condition{attribute: attribute, value: expression}
+	if strings.HasPrefix(c.value, "!") {
+		c.negate = true
+		c.value = c.value[1:]
+	}
+	if op := operatorPattern.FindStringSubmatch(c.value); op != nil {
+		c.operator = op[1]
+		c.value = c.value[len(op[0]):]
+	} else if c.negate {
+		c.operator = opContains
+	}
+
+	switch c.operator {
+	case opRegex:
+		regex, err := regexp.Compile(c.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex for %s: %s", attribute, err)
+		}
+		c.regex = regex
+	case opGt, opGe, opLt, opLe:
+		if number, ok := parseNumber(c.value); ok {
+			c.number = &number
+		} else if t, ok := parseTime(c.value); ok {
+			c.time = &t
+		} else {
+			return nil, fmt.Errorf("%s for %s needs a number, size or time, got `%s`", c.operator, attribute, c.value)
+		}
+	case opIn:
+		c.values = strings.Split(c.value, "|")
+	}
+
+	conditionCache.store(key, c)
+	return c, nil
+}
+
+// match tests the condition on an item. If like is set, values without
+// operator are LIKE patterns, otherwise they must be contained.
+func (c *condition) match(item gostore.Item, like bool) bool {
+	value, ok := lookup(item, c.attribute)
+	if c.operator == opPlain {
+		if like {
+			return ok && likeRegex(c.value).MatchString(stringValue(value))
+		}
+		return strings.Contains(fmt.Sprint(value), c.value)
+	}
+	if !ok || value == nil {
+		return c.negate
+	}
+	return c.compare(value) != c.negate
+}
+
+func (c *condition) compare(value interface{}) bool {
+	s := stringValue(value)
+	switch c.operator {
+	case opEq:
+		return s == c.value
+	case opPrefix:
+		return strings.HasPrefix(s, c.value)
+	case opSuffix:
+		return strings.HasSuffix(s, c.value)
+	case opContains:
+		return strings.Contains(s, c.value)
+	case opRegex:
+		return c.regex.MatchString(s)
+	case opIn:
+		for _, v := range c.values {
+			if s == v {
+				return true
+			}
+		}
+		return false
+	}
+
+	var cmp int
+	switch {
+	case c.number != nil:
+		number, ok := numberValue(value)
+		if !ok {
+			return false
+		}
+		cmp = compareFloat(number, *c.number)
+	case c.time != nil:
+		t, ok := parseTime(s)
+		if !ok {
+			return false
+		}
+		cmp = compareFloat(float64(t.Sub(*c.time)), 0)
+	}
+	switch c.operator {
+	case opGt:
+		return cmp > 0
+	case opGe:
+		return cmp >= 0
+	case opLt:
+		return cmp < 0
+	case opLe:
+		return cmp <= 0
+	}
+	return false
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// lookup returns the value of an attribute. Dotted attributes are looked up
+// in nested objects unless the item contains the attribute itself.
+func lookup(item map[string]interface{}, attribute string) (interface{}, bool) {
+	if value, ok := item[attribute]; ok {
+		return value, true
+	}
+	parts := strings.SplitN(attribute, ".", 2)
+	if len(parts) == 2 {
+		if nested, ok := item[parts[0]].(map[string]interface{}); ok {
+			return lookup(nested, parts[1])
+		}
+	}
+	return nil, false
+}
+
+func stringValue(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+func numberValue(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	case string:
+		return parseNumber(value)
+	}
+	return 0, false
+}
+
+var (
+	sizePattern = regexp.MustCompile(`^(-?[0-9]*\.?[0-9]+)\s*([a-zA-Z]*)$`)
+	sizeUnits   = map[string]float64{
+		"": 1, "b": 1,
+		"kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12,
+		"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40,
+	}
+)
+
+// parseNumber parses a number with an optional size unit.
+func parseNumber(s string) (float64, bool) {
+	match := sizePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return 0, false
+	}
+	unit, ok := sizeUnits[strings.ToLower(match[2])]
+	if !ok {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(match[1], 64)
+	return number * unit, err == nil
+}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+var likeCache = newLRUCache(filterCacheSize)
+
+// likeRegex converts an SQL LIKE pattern into a regular expression.
+func likeRegex(pattern string) *regexp.Regexp {
+	if regex, ok := likeCache.load(pattern); ok {
+		return regex.(*regexp.Regexp)
+	}
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	regex := regexp.MustCompile(b.String())
+	likeCache.store(pattern, regex)
+	return regex
+}
+
+// Validate checks that all expressions of the filter can be parsed.
+func (f Filter) Validate() error {
+	for _, conditions := range f {
+		for _, attribute := range sortedKeys(conditions) {
+			if _, err := parseCondition(attribute, conditions[attribute]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Match tests if an item matches the filter.
+func (f Filter) Match(item gostore.Item) bool {
+	return f.match(item, false)
+}
+
+func (f Filter) match(item gostore.Item, like bool) bool {
+	if f == nil {
+		return true
+	}
+	for _, conditions := range f {
+		if matchConditions(conditions, item, like) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchConditions(conditions map[string]string, item gostore.Item, like bool) bool {
+	for attribute, expression := range conditions {
+		c, err := parseCondition(attribute, expression)
+		if err != nil || !c.match(item, like) {
+			return false
+		}
+	}
+	return true
+}
+
+// And returns a filter that additionally requires the given conditions in
+// every entry. Attributes that are already part of an entry are not changed.
+func (f Filter) And(conditions map[string]string) Filter {
+	if f == nil {
+		f = Filter{{}}
+	}
+	var combined Filter
+	for _, entry := range f {
+		merged := map[string]string{}
+		for attribute, expression := range conditions {
+			merged[attribute] = expression
+		}
+		for attribute, expression := range entry {
+			merged[attribute] = expression
+		}
+		combined = append(combined, merged)
+	}
+	return combined
+}
+
+func (f Filter) toCommandline() []string {
+	var cmd []string
+	for _, conditions := range f {
+		var elements []string
+		for _, key := range sortedKeys(conditions) {
+			elements = append(elements, escapeFilter(key, true)+"="+escapeFilter(conditions[key], false))
+		}
+		cmd = append(cmd, "--filter", strings.Join(elements, ","))
+	}
+	return cmd
+}
+
+// escapeFilter escapes backslashes and commas, and equal signs in keys, so
+// the filter can be split in script plugins.
+func escapeFilter(s string, key bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, ",", `\,`)
+	if key {
+		s = strings.ReplaceAll(s, "=", `\=`)
+	}
+	return s
+}
+
+// lruCache is a concurrency-safe cache that drops the least recently used
+// entry when it is full.
+type lruCache struct {
+	mutex   sync.Mutex
+	size    int
+	order   *list.List // of *lruEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{size: size, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *lruCache) load(key string) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruEntry).value, true
+}
+
+func (c *lruCache) store(key string, value interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*lruEntry).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
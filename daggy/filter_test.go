@@ -0,0 +1,134 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/forensicanalysis/forensicstore/gostore"
+)
+
+func TestFilter_Match(t *testing.T) {
+	item := gostore.Item{
+		"name":       `C:\Windows\System32\winevt\Logs\System.evtx`,
+		"size":       float64(2 * 1024 * 1024),
+		"attributes": map[string]interface{}{"created": "2020-02-03T04:05:06Z"},
+	}
+	tests := []struct {
+		attribute  string
+		expression string
+		want       bool
+	}{
+		{"name", "System32", true},
+		{"name", "eq:System.evtx", false},
+		{"name", "suffix:.evtx", true},
+		{"name", "prefix:C:", true},
+		{"name", "contains:winevt", true},
+		{"name", "!contains:System32", false},
+		{"name", "!System32", false},
+		{"name", `regex:Logs\\[A-Z]`, true},
+		{"size", "gt:1MB", true},
+		{"size", "le:2MiB", true},
+		{"size", "lt:2MiB", false},
+		{"size", "eq:2097152", true},
+		{"size", "in:1|2097152", true},
+		{"attributes.created", "ge:2020-02-03", true},
+		{"attributes.created", "lt:2020-01-01T00:00:00Z", false},
+		{"missing", "eq:x", false},
+		{"missing", "!eq:x", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.attribute+" "+tt.expression, func(t *testing.T) {
+			filter := Filter{{tt.attribute: tt.expression}}
+			if got := filter.Match(item); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantErr bool
+	}{
+		{"valid", Filter{{"name": "regex:^a", "size": "gt:10KiB"}}, false},
+		{"invalid regex", Filter{{"name": "regex:("}}, true},
+		{"invalid comparison", Filter{{"size": "gt:big"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFilter_toCommandline(t *testing.T) {
+	filter := Filter{{"type": "file", "key": `HKLM\Software,x`, "a=b": "in:a|b"}}
+	want := []string{"--filter", `a\=b=in:a|b,key=HKLM\\Software\,x,type=file`}
+	if got := filter.toCommandline(); !reflect.DeepEqual(got, want) {
+		t.Errorf("toCommandline() = %v, want %v", got, want)
+	}
+}
+
+func TestFilter_And(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   Filter
+	}{
+		{"nil", nil, Filter{{"name": "suffix:.pf"}}},
+		{"merge", Filter{{"type": "file"}, {"size": "gt:1"}}, Filter{{"type": "file", "name": "suffix:.pf"}, {"size": "gt:1", "name": "suffix:.pf"}}},
+		{"keep", Filter{{"name": "a.pf"}}, Filter{{"name": "a.pf"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.And(map[string]string{"name": "suffix:.pf"}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("And() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_lruCache(t *testing.T) {
+	cache := newLRUCache(2)
+	cache.store("a", 1)
+	cache.store("b", 2)
+	cache.load("a")
+	cache.store("c", 3)
+
+	if _, ok := cache.load("b"); ok {
+		t.Error("load(b) found least recently used entry")
+	}
+	for key, want := range map[string]int{"a": 1, "c": 3} {
+		if got, ok := cache.load(key); !ok || got != want {
+			t.Errorf("load(%s) = %v, %v, want %d", key, got, ok, want)
+		}
+	}
+	if cache.order.Len() != 2 || len(cache.entries) != 2 {
+		t.Errorf("cache has %d entries, want 2", len(cache.entries))
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/dag"
+)
+
+// typeColors are the node colors for every task type.
+var typeColors = map[string]string{
+	"bash":       "#a6cee3",
+	"plugin":     "#b2df8a",
+	"docker":     "#fdbf6f",
+	"dockerfile": "#cab2d6",
+	typeGroup:    "#ffffff",
+	typeWorkflow: "#fb9a99",
+}
+
+// statusColors are the node colors for the status of the last execution.
+var statusColors = map[Status]string{
+	StatusRunning:   "#ffff99",
+	StatusSucceeded: "#4daf4a",
+	StatusFailed:    "#e41a1c",
+	StatusSkipped:   "#d9d9d9",
+	StatusCached:    "#a1d99b",
+}
+
+type graphNode struct {
+	id, name, label, color string
+}
+
+// WriteGraph writes the graph of tasks in the dot or mermaid format. Nodes are
+// colored by task type or, if results are given, by the status of the task.
+// SetupGraph must be called before.
+func (workflow *Workflow) WriteGraph(w io.Writer, format string, results Results) error {
+	if workflow.graph == nil {
+		return errors.New("workflow graph is not set up")
+	}
+
+	var names []string
+	for _, vertex := range workflow.graph.Vertices() {
+		names = append(names, dag.VertexName(vertex))
+	}
+	sort.Strings(names)
+	nodes := map[string]*graphNode{}
+	for i, name := range names {
+		task := workflow.Tasks[name]
+		node := &graphNode{id: fmt.Sprintf("t%d", i), name: name, label: name + "\n" + task.Type, color: typeColors[task.Type]}
+		if result, ok := results[name]; ok {
+			node.label += "\n" + string(result.Status)
+			node.color = statusColors[result.Status]
+		}
+		if node.color == "" {
+			node.color = "#ffffff"
+		}
+		nodes[name] = node
+	}
+
+	var edges [][2]string
+	for _, edge := range workflow.graph.Edges() {
+		edges = append(edges, [2]string{dag.VertexName(edge.Source()), dag.VertexName(edge.Target())})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+
+	var b strings.Builder
+	switch format {
+	case "dot":
+		b.WriteString("digraph workflow {\n")
+		b.WriteString("  rankdir=LR;\n")
+		b.WriteString("  node [shape=box, style=\"rounded,filled\"];\n")
+		for _, name := range names {
+			node := nodes[name]
+			fmt.Fprintf(&b, "  %s [label=%s, fillcolor=\"%s\"];\n", dotQuote(node.name), dotQuote(node.label), node.color)
+		}
+		for _, edge := range edges {
+			fmt.Fprintf(&b, "  %s -> %s;\n", dotQuote(edge[0]), dotQuote(edge[1]))
+		}
+		b.WriteString("}\n")
+	case "mermaid":
+		b.WriteString("graph LR\n")
+		for _, name := range names {
+			node := nodes[name]
+			fmt.Fprintf(&b, "  %s[\"%s\"]\n", node.id, mermaidEscape(node.label))
+		}
+		for _, edge := range edges {
+			fmt.Fprintf(&b, "  %s --> %s\n", nodes[edge[0]].id, nodes[edge[1]].id)
+		}
+		for _, name := range names {
+			fmt.Fprintf(&b, "  style %s fill:%s\n", nodes[name].id, nodes[name].color)
+		}
+	default:
+		return fmt.Errorf("unknown graph format `%s`", format)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+}
+
+func mermaidEscape(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	return strings.ReplaceAll(s, "\n", "<br/>")
+}
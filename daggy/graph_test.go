@@ -0,0 +1,72 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWorkflow_WriteGraph(t *testing.T) {
+	workflow := &Workflow{Tasks: map[string]Task{
+		"a": {Type: "bash", Command: "true"},
+		"b": {Type: "docker", Image: "alpine", Requires: []string{"a"}},
+	}}
+	workflow.SetupGraph()
+
+	tests := []struct {
+		name    string
+		format  string
+		results Results
+		want    string
+		wantErr bool
+	}{
+		{"dot", "dot", nil, `digraph workflow {
+  rankdir=LR;
+  node [shape=box, style="rounded,filled"];
+  "a" [label="a\nbash", fillcolor="#a6cee3"];
+  "b" [label="b\ndocker", fillcolor="#fdbf6f"];
+  "a" -> "b";
+}
+`, false},
+		{"mermaid status", "mermaid", Results{"a": {Status: StatusFailed}, "b": {Status: StatusSkipped}}, `graph LR
+  t0["a<br/>bash<br/>failed"]
+  t1["b<br/>docker<br/>skipped"]
+  t0 --> t1
+  style t0 fill:#e41a1c
+  style t1 fill:#d9d9d9
+`, false},
+		{"unknown format", "png", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := workflow.WriteGraph(buf, tt.format, tt.results)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteGraph() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("WriteGraph() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/client"
+)
+
+// Images returns the images of all docker tasks of the workflow and its
+// nested workflows and the base images of the dockerfiles in pluginDir.
+func (workflow *Workflow) Images(pluginDir string) ([]string, error) {
+	seen := map[string]bool{}
+	if err := workflow.collectImages(pluginDir, seen); err != nil {
+		return nil, err
+	}
+	var images []string
+	for image := range seen {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+func (workflow *Workflow) collectImages(pluginDir string, seen map[string]bool) error {
+	for _, task := range workflow.Tasks {
+		switch {
+		case task.Type == "docker" && task.Image != "":
+			seen[task.Image] = true
+		case task.Type == "dockerfile":
+			images, err := baseImages(filepath.Join(pluginDir, task.Dockerfile, "Dockerfile"))
+			if err != nil {
+				return err
+			}
+			for _, image := range images {
+				seen[image] = true
+			}
+		case task.Type == typeWorkflow && task.workflow != nil:
+			if err := task.workflow.collectImages(pluginDir, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// baseImages returns the images of the FROM instructions of a dockerfile.
+// Earlier build stages, scratch and images set by build arguments are left
+// out.
+func baseImages(dockerfilePath string) ([]string, error) {
+	f, err := os.Open(dockerfilePath) // #nosec
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stages := map[string]bool{"scratch": true}
+	var images []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+		fields = fields[1:]
+		for len(fields) > 0 && strings.HasPrefix(fields[0], "--") {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		image := fields[0]
+		if !stages[strings.ToLower(image)] && !strings.Contains(image, "$") {
+			images = append(images, image)
+		}
+		if len(fields) >= 3 && strings.EqualFold(fields[1], "AS") {
+			stages[strings.ToLower(fields[2])] = true
+		}
+	}
+	return images, scanner.Err()
+}
+
+// SaveImages writes the images as a tar archive to w, which can be loaded on
+// hosts without registry access with LoadImages. Images that are not present
+// are pulled first.
+func SaveImages(ctx context.Context, images []string, w io.Writer) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	for _, image := range images {
+		if err := ensureImage(ctx, cli, image, PullIfNotPresent); err != nil {
+			return err
+		}
+	}
+	archive, err := cli.ImageSave(ctx, images)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	_, err = io.Copy(w, archive)
+	return err
+}
+
+// LoadImages loads the images of a tar archive written by SaveImages.
+func LoadImages(ctx context.Context, archive io.Reader) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	resp, err := cli.ImageLoad(ctx, archive, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(os.Stderr, resp.Body)
+	return err
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWorkflow_Images(t *testing.T) {
+	dir := writeWorkflows(t, map[string]string{
+		"workflow.yml": "tasks:\n  plaso:\n    type: docker\n    image: log2timeline/plaso\n  jq:\n    type: dockerfile\n    dockerfile: jq\n  nested:\n    type: workflow\n    workflow: sub.yml\n",
+		"sub.yml":      "tasks:\n  alpine:\n    type: docker\n    image: alpine\n  plaso:\n    type: docker\n    image: log2timeline/plaso\n  list:\n    type: bash\n    command: ls\n",
+	})
+	defer os.RemoveAll(dir)
+
+	workflow, err := Parse(filepath.Join(dir, "workflow.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pluginDir := filepath.Join(dir, "plugins")
+	if err := os.MkdirAll(filepath.Join(pluginDir, "jq"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	dockerfile := "FROM golang:1.14 AS build\nRUN go build\nFROM --platform=linux/amd64 debian:buster\nCOPY --from=build /app /app\nFROM build\n"
+	if err := ioutil.WriteFile(filepath.Join(pluginDir, "jq", "Dockerfile"), []byte(dockerfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := workflow.Images(pluginDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"alpine", "debian:buster", "golang:1.14", "log2timeline/plaso"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Images() = %v, want %v", got, want)
+	}
+}
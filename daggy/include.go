@@ -0,0 +1,236 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// typeWorkflow is the type of tasks that run a nested workflow. The tasks of
+// the nested workflow are recorded as <task>/<nested task>.
+const typeWorkflow = "workflow"
+
+// resolveIncludes adds the tasks of all included workflow files. Vars, with and
+// resources of included files are added unless the including file defines
+// them. A task defined in more than one file is an error.
+func (workflow *Workflow) resolveIncludes(parents []string) error {
+	var errs ValidationErrors
+	for i, include := range workflow.Include {
+		includePath := relativeTo(workflow.source.file, include)
+		if cycle := fileCycle(parents, includePath); cycle != nil {
+			errs = append(errs, workflow.validationError("", "include cycle: "+strings.Join(cycle, ", "), "include", strconv.Itoa(i)))
+			continue
+		}
+		included, err := readWorkflow(includePath, parents)
+		if err != nil {
+			errs = append(errs, workflow.nestedError("", fmt.Sprintf("include %s", include), err, "include", strconv.Itoa(i))...)
+			continue
+		}
+
+		if workflow.Tasks == nil {
+			workflow.Tasks = map[string]Task{}
+		}
+		for _, name := range included.taskNames() {
+			if _, ok := workflow.Tasks[name]; ok {
+				errs = append(errs, workflow.validationError("", fmt.Sprintf("task %s of %s is already defined", name, include), "include", strconv.Itoa(i)))
+				continue
+			}
+			workflow.Tasks[name] = included.Tasks[name]
+			workflow.source.tasks[name] = included.taskSource(name)
+		}
+		for name, v := range included.Vars {
+			if _, ok := workflow.Vars[name]; !ok {
+				if workflow.Vars == nil {
+					workflow.Vars = map[string]Var{}
+				}
+				workflow.Vars[name] = v
+			}
+		}
+		for name, value := range included.Arguments {
+			if _, ok := workflow.Arguments[name]; !ok {
+				if workflow.Arguments == nil {
+					workflow.Arguments = Arguments{}
+				}
+				workflow.Arguments[name] = value
+			}
+		}
+		for name, class := range included.Resources {
+			if _, ok := workflow.Resources[name]; !ok {
+				if workflow.Resources == nil {
+					workflow.Resources = map[string]ResourceClass{}
+				}
+				workflow.Resources[name] = class
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// resolveNested parses and validates the workflows of all tasks with the type
+// workflow. Tasks of included files are resolved already.
+func (workflow *Workflow) resolveNested(parents []string) error {
+	var errs ValidationErrors
+	for _, name := range workflow.taskNames() {
+		task := workflow.Tasks[name]
+		if task.Type != typeWorkflow || task.Workflow == "" || task.workflow != nil {
+			continue
+		}
+		workflowPath := relativeTo(workflow.source.file, task.Workflow)
+		if cycle := fileCycle(parents, workflowPath); cycle != nil {
+			errs = append(errs, workflow.validationError(name, "workflow cycle: "+strings.Join(cycle, ", "), "tasks", name, "workflow"))
+			continue
+		}
+		nested, err := readWorkflow(workflowPath, parents)
+		if err == nil {
+			if nestedErrs := nested.validateTasks(); len(nestedErrs) > 0 {
+				err = nestedErrs
+			}
+		}
+		if err != nil {
+			errs = append(errs, workflow.nestedError(name, "workflow", err, "tasks", name, "workflow")...)
+			continue
+		}
+		task.workflow = nested
+		workflow.Tasks[name] = task
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// nestedError returns the validation errors of an included or nested file as
+// they are. Other errors, e.g. a missing file, are located in the workflow.
+func (workflow *Workflow) nestedError(task, prefix string, err error, path ...string) ValidationErrors {
+	if errs, ok := err.(ValidationErrors); ok {
+		return errs
+	}
+	return ValidationErrors{workflow.validationError(task, prefix+": "+err.Error(), path...)}
+}
+
+func (workflow *Workflow) taskSource(name string) *source {
+	if taskSource, ok := workflow.source.tasks[name]; ok {
+		return taskSource
+	}
+	return workflow.source
+}
+
+// relativeTo resolves a path relative to the directory of a workflow file.
+func relativeTo(workflowFile, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(workflowFile), path)
+}
+
+// fileCycle returns the chain of files from the first occurrence of
+// workflowFile in parents, or nil if workflowFile is not in parents.
+func fileCycle(parents []string, workflowFile string) []string {
+	for i, parent := range parents {
+		if sameFile(parent, workflowFile) {
+			return append(append([]string{}, parents[i:]...), workflowFile)
+		}
+	}
+	return nil
+}
+
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
+
+// runWorkflow runs the nested workflow of a task. The nested workflow gets the
+// arguments of the run merged with the with block and the secrets of the task,
+// shares the journal, the max_parallel slots and the resource classes of the
+// workflow and adds the results of its tasks as <task>/<nested task>.
+func (r *run) runWorkflow(ctx context.Context, taskName string, task Task) error {
+	if task.workflow == nil {
+		return fmt.Errorf("workflow %s is not parsed", task.Workflow)
+	}
+	nested := *task.workflow
+	nested.Resume = r.workflow.Resume
+	nested.NoCache = r.workflow.NoCache
+	nested.Container = nested.Container.merge(r.workflow.Container)
+	nested.slots = r.workflow.slots
+	nested.classSlots = map[string]chan struct{}{}
+	for class, slots := range task.workflow.classSlots {
+		// resource classes only the nested workflow declares
+		nested.classSlots[class] = slots
+	}
+	for class, slots := range r.workflow.classSlots {
+		nested.classSlots[class] = slots
+	}
+
+	arguments := Arguments{}
+	for name, value := range r.arguments {
+		arguments[name] = value
+	}
+	for name, value := range task.Arguments {
+		arguments[name] = value
+	}
+	for name, value := range r.taskSecrets(task) {
+		// only secret vars keep the value out of the arguments
+		if !nested.Vars[name].Secret {
+			return fmt.Errorf("workflow %s has no secret var `%s`", task.Workflow, name)
+		}
+		arguments[name] = value
+	}
+	b, err := nested.bind(arguments)
+	if err != nil {
+		return err
+	}
+	child := &run{
+		workflow:   &nested,
+		workingDir: r.workingDir,
+		pluginDir:  r.pluginDir,
+		plugins:    r.plugins,
+		arguments:  b.arguments,
+		vars:       b.vars,
+		secrets:    b.secrets,
+		journal:    r.journal,
+		id:         r.id,
+		images:     r.images,
+		prefix:     r.prefix + taskName + "/",
+		results:    Results{},
+		resumed:    map[string]bool{},
+		blocking:   map[string]bool{},
+	}
+	results, err := child.walk(ctx)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for name, result := range results {
+		r.results[taskName+"/"+name] = result
+	}
+	return err
+}
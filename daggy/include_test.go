@@ -0,0 +1,180 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeWorkflows(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "daggyinclude")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParse_include(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		wantErrs []string
+	}{
+		{"include", map[string]string{
+			"workflow.yml": "include: [common.yml]\ntasks:\n  a:\n    type: bash\n    command: true\n    requires: [b]\n",
+			"common.yml":   "vars:\n  os:\n    default: windows\ntasks:\n  b:\n    type: bash\n    command: echo ${{ vars.os }}\n",
+		}, nil},
+		{"error in include", map[string]string{
+			"workflow.yml": "include: [common.yml]\ntasks:\n  a:\n    type: bash\n    command: true\n",
+			"common.yml":   "tasks:\n  b:\n    type: foo\n",
+		}, []string{"common.yml:3:5: task b: unknown type `foo`"}},
+		{"collision", map[string]string{
+			"workflow.yml": "include: [common.yml]\ntasks:\n  b:\n    type: bash\n    command: true\n",
+			"common.yml":   "tasks:\n  b:\n    type: bash\n    command: true\n",
+		}, []string{"workflow.yml:1:11: task b of common.yml is already defined"}},
+		{"include cycle", map[string]string{
+			"workflow.yml": "include: [common.yml]\n",
+			"common.yml":   "include: [workflow.yml]\n",
+		}, []string{"common.yml:1:11: include cycle: "}},
+		{"missing include", map[string]string{
+			"workflow.yml": "include: [missing.yml]\n",
+		}, []string{"workflow.yml:1:11: include missing.yml: open "}},
+		{"nested workflow", map[string]string{
+			"workflow.yml": "tasks:\n  a:\n    type: workflow\n    workflow: sub.yml\n    with:\n      name: x\n",
+			"sub.yml":      "tasks:\n  b:\n    type: bash\n    command: true\n",
+		}, nil},
+		{"error in nested workflow", map[string]string{
+			"workflow.yml": "tasks:\n  a:\n    type: workflow\n    workflow: sub.yml\n",
+			"sub.yml":      "tasks:\n  b:\n    type: bash\n",
+		}, []string{"sub.yml:2:3: task b: missing command for type bash"}},
+		{"missing workflow", map[string]string{
+			"workflow.yml": "tasks:\n  a:\n    type: workflow\n",
+		}, []string{"workflow.yml:2:3: task a: missing workflow for type workflow"}},
+		{"workflow cycle", map[string]string{
+			"workflow.yml": "tasks:\n  a:\n    type: workflow\n    workflow: workflow.yml\n",
+		}, []string{"workflow.yml:4:5: task a: workflow cycle: "}},
+		{"slash in task name", map[string]string{
+			"workflow.yml": "tasks:\n  a/b:\n    type: bash\n    command: true\n",
+		}, []string{"workflow.yml:2:3: task a/b: task names must not contain /"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeWorkflows(t, tt.files)
+			defer os.RemoveAll(dir)
+
+			_, err := Parse(filepath.Join(dir, "workflow.yml"))
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("Parse() error = %v", err)
+				}
+				return
+			}
+			errs, ok := err.(ValidationErrors)
+			if !ok {
+				t.Fatalf("Parse() error = %v, want ValidationErrors", err)
+			}
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("Parse() errors = %v, want %v", errs, tt.wantErrs)
+			}
+			for i, want := range tt.wantErrs {
+				if got := strings.TrimPrefix(errs[i].Error(), dir+string(filepath.Separator)); !strings.HasPrefix(got, want) {
+					t.Errorf("Parse() error %d = %s, want %s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkflow_RunNested(t *testing.T) {
+	dir := writeWorkflows(t, map[string]string{
+		"workflow.yml": "include: [common.yml]\ntasks:\n  hash:\n    type: workflow\n    workflow: sub.yml\n    requires: [setup]\n    with:\n      name: md5\n  report:\n    type: bash\n    command: ls > report\n    requires: [hash]\n",
+		"common.yml":   "tasks:\n  setup:\n    type: bash\n    command: touch setup\n",
+		"sub.yml":      "vars:\n  name:\n    required: true\ntasks:\n  first:\n    type: bash\n    command: touch ${{ vars.name }}\n  second:\n    type: bash\n    command: test -f ${{ vars.name }}\n    requires: [first]\n",
+	})
+	defer os.RemoveAll(dir)
+	storeDir := filepath.Join(dir, "store")
+	if err := os.Mkdir(storeDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	workflow, err := Parse(filepath.Join(dir, "workflow.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workflow.SetupGraph()
+	results, err := workflow.Run(context.Background(), storeDir, "", nil, Arguments{})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, name := range []string{"setup", "hash", "hash/first", "hash/second", "report"} {
+		if result, ok := results[name]; !ok || result.Status != StatusSucceeded {
+			t.Errorf("task %s: result = %+v, want %s", name, result, StatusSucceeded)
+		}
+	}
+
+	journal, err := OpenJournal(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := journal.Tasks["hash/second"]; !ok {
+		t.Error("journal has no record of hash/second")
+	}
+	b, err := ioutil.ReadFile(filepath.Join(storeDir, "report"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "md5") {
+		t.Errorf("report = %q, want md5", b)
+	}
+}
+
+func TestWorkflow_RunNestedResources(t *testing.T) {
+	heavy := "    type: bash\n    command: mkdir lock && sleep 0.2 && rmdir lock\n    resources:\n      class: heavy\n"
+	dir := writeWorkflows(t, map[string]string{
+		"workflow.yml": "resources:\n  heavy:\n    max_concurrent: 1\ntasks:\n  a:\n" + heavy + "  nested:\n    type: workflow\n    workflow: sub.yml\n",
+		"sub.yml":      "tasks:\n  b:\n" + heavy + "  c:\n" + heavy,
+	})
+	defer os.RemoveAll(dir)
+	storeDir := filepath.Join(dir, "store")
+	if err := os.Mkdir(storeDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	workflow, err := Parse(filepath.Join(dir, "workflow.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workflow.SetupGraph()
+	if _, err := workflow.Run(context.Background(), storeDir, "", nil, Arguments{}); err != nil {
+		t.Fatalf("Run() error = %v, want tasks of class heavy to run one after another", err)
+	}
+}
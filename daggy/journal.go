@@ -0,0 +1,209 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JournalFile is the name of the journal in the forensicstore folder.
+const JournalFile = "workflow-journal.json"
+
+// journalInterval is the minimal time between two writes of the journal, so
+// workflows with many short tasks do not rewrite it for every task.
+const journalInterval = time.Second
+
+// A TaskRecord stores the outcome of the last execution of a task.
+type TaskRecord struct {
+	TaskResult
+	InputHash string `json:"input_hash"`
+	CacheKey  string `json:"cache_key,omitempty"`
+}
+
+// A Journal records the state of all tasks that were run on a forensicstore,
+// so failed workflows can be resumed.
+type Journal struct {
+	Tasks map[string]*TaskRecord `json:"tasks"`
+	path  string
+	mutex sync.Mutex
+	saved time.Time   // time of the last write
+	timer *time.Timer // writes changes made after the last write
+}
+
+// OpenJournal reads the journal of the forensicstore in storeDir. A new journal
+// is returned if none exists.
+func OpenJournal(storeDir string) (*Journal, error) {
+	journal := &Journal{Tasks: map[string]*TaskRecord{}, path: filepath.Join(storeDir, JournalFile)}
+	b, err := ioutil.ReadFile(journal.path)
+	if os.IsNotExist(err) {
+		return journal, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, journal); err != nil {
+		return nil, fmt.Errorf("could not read journal %s: %s", journal.path, err)
+	}
+	if journal.Tasks == nil {
+		journal.Tasks = map[string]*TaskRecord{}
+	}
+	return journal, nil
+}
+
+// Succeeded returns true if the task succeeded in an earlier run with the same
+// inputs.
+func (journal *Journal) Succeeded(taskName, inputHash string) bool {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	record, ok := journal.Tasks[taskName]
+	return ok && record.succeeded() && record.InputHash == inputHash
+}
+
+// cached returns true if the task succeeded in an earlier run with the same
+// cache key.
+func (journal *Journal) cached(taskName, cacheKey string) bool {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	record, ok := journal.Tasks[taskName]
+	return ok && cacheKey != "" && record.succeeded() && record.CacheKey == cacheKey
+}
+
+func (record *TaskRecord) succeeded() bool {
+	return record.Status == StatusSucceeded || record.Status == StatusCached
+}
+
+// Results returns the recorded result of every task.
+func (journal *Journal) Results() Results {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	results := Results{}
+	for name, record := range journal.Tasks {
+		result := record.TaskResult
+		results[name] = &result
+	}
+	return results
+}
+
+// outputs returns the recorded outputs of a task.
+func (journal *Journal) outputs(taskName string) Outputs {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	if record, ok := journal.Tasks[taskName]; ok {
+		return record.Outputs
+	}
+	return nil
+}
+
+func (journal *Journal) start(taskName, inputHash, cacheKey string) error {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	journal.Tasks[taskName] = &TaskRecord{TaskResult{Status: StatusRunning, Start: now()}, inputHash, cacheKey}
+	return journal.save()
+}
+
+func (journal *Journal) finish(taskName string, result *TaskResult) error {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	journal.Tasks[taskName].TaskResult = *result
+	return journal.save()
+}
+
+// Flush writes changes that were not written yet.
+func (journal *Journal) Flush() error {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	if journal.timer == nil {
+		return nil
+	}
+	journal.timer.Stop()
+	journal.timer = nil
+	return journal.write()
+}
+
+// save writes the journal at most once per journalInterval. Later changes are
+// written by a timer or Flush.
+func (journal *Journal) save() error {
+	wait := journalInterval - time.Since(journal.saved)
+	if wait <= 0 {
+		return journal.write()
+	}
+	if journal.timer == nil {
+		journal.timer = time.AfterFunc(wait, func() {
+			journal.mutex.Lock()
+			defer journal.mutex.Unlock()
+			if journal.timer == nil {
+				return
+			}
+			journal.timer = nil
+			if err := journal.write(); err != nil {
+				log.Println("could not write journal:", err)
+			}
+		})
+	}
+	return nil
+}
+
+// write writes the journal to a temporary file first, so an interrupted write
+// does not destroy the journal.
+func (journal *Journal) write() error {
+	journal.saved = time.Now()
+	b, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := journal.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, journal.path)
+}
+
+// inputHash identifies the inputs of a task, i.e. its definition and the
+// arguments and vars of the workflow.
+func inputHash(task Task, arguments Arguments, vars map[string]string) string {
+	b, _ := json.Marshal(struct {
+		Task      Task
+		Arguments Arguments
+		Vars      map[string]string `json:",omitempty"`
+	}{task, arguments, vars})
+	return fmt.Sprintf("%x", sha256.Sum256(b))
+}
+
+// exitCode returns the exit code of a failed command, 0 for no error and -1 for
+// errors without an exit code.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*ExitError); ok {
+		return exitErr.Code
+	}
+	return -1
+}
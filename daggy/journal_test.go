@@ -0,0 +1,150 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorkflow_Resume(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggyjournal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	workflow := Workflow{Tasks: map[string]Task{
+		"first":  {Type: "bash", Command: "echo run >> first.log"},
+		"second": {Type: "bash", Command: "test -f ok", Requires: []string{"first"}},
+	}}
+	workflow.SetupGraph()
+
+	if _, err := workflow.Run(context.Background(), storeDir, "", nil, nil); err == nil {
+		t.Fatal("Run() expected error")
+	}
+
+	journal, err := OpenJournal(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := journal.Tasks["first"].Status; got != StatusSucceeded {
+		t.Errorf("first status = %v, want %v", got, StatusSucceeded)
+	}
+	if got := journal.Tasks["second"]; got.Status != StatusFailed || got.ExitCode != 1 {
+		t.Errorf("second status = %v (%d), want %v (1)", got.Status, got.ExitCode, StatusFailed)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(storeDir, "ok"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	workflow.Resume = true
+	if _, err := workflow.Run(context.Background(), storeDir, "", nil, nil); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(storeDir, "first.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs := strings.Count(string(b), "run"); runs != 1 {
+		t.Errorf("first ran %d times, want 1", runs)
+	}
+
+	journal, err = OpenJournal(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := journal.Tasks["second"].Status; got != StatusSucceeded {
+		t.Errorf("second status = %v, want %v", got, StatusSucceeded)
+	}
+}
+
+func TestWorkflow_ResumeChangedInput(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggyjournal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	workflow := Workflow{Tasks: map[string]Task{
+		"first":  {Type: "bash", Command: "echo run >> first.log"},
+		"second": {Type: "bash", Command: "echo run >> second.log", Requires: []string{"first"}},
+	}, Resume: true}
+	workflow.SetupGraph()
+
+	if _, err := workflow.Run(context.Background(), storeDir, "", nil, nil); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, err := workflow.Run(context.Background(), storeDir, "", nil, Arguments{"foo": "bar"}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for _, log := range []string{"first.log", "second.log"} {
+		b, err := ioutil.ReadFile(filepath.Join(storeDir, log))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runs := strings.Count(string(b), "run"); runs != 2 {
+			t.Errorf("%s has %d runs, want 2", log, runs)
+		}
+	}
+}
+
+func TestJournal_save(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggyjournal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	journal, err := OpenJournal(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if err := journal.start(name, "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	written, err := OpenJournal(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written.Tasks) != 1 {
+		t.Errorf("journal was written with %d tasks, want 1 until the next interval", len(written.Tasks))
+	}
+
+	if err := journal.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if written, err = OpenJournal(storeDir); err != nil {
+		t.Fatal(err)
+	}
+	if len(written.Tasks) != 3 {
+		t.Errorf("Flush() wrote %d tasks, want 3", len(written.Tasks))
+	}
+}
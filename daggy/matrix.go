@@ -0,0 +1,275 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/forensicanalysis/forensicstore/gostore"
+	"github.com/hashicorp/terraform/tfdiags"
+)
+
+// typeGroup is the type of the tasks that stand for all expansions of a
+// matrix or for_each task, so other tasks can require the whole group.
+const typeGroup = "group"
+
+// A ForEach runs a task once for every item of a type in the forensicstore
+// that matches the filter. Every run only gets its item in the filter.
+type ForEach struct {
+	Type   string `yaml:"type"`
+	Filter Filter `yaml:"filter"`
+}
+
+// matrixCombinations returns all combinations of the matrix values.
+func matrixCombinations(matrix map[string][]string) []map[string]string {
+	combinations := []map[string]string{{}}
+	for _, key := range matrixKeys(matrix) {
+		var next []map[string]string
+		for _, combination := range combinations {
+			for _, value := range matrix[key] {
+				c := map[string]string{key: value}
+				for k, v := range combination {
+					c[k] = v
+				}
+				next = append(next, c)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+func matrixKeys(matrix map[string][]string) []string {
+	var keys []string
+	for key := range matrix {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// expandMatrices replaces all tasks with a matrix by one task for every
+// combination and a group task.
+func expandMatrices(tasks map[string]Task) map[string]Task {
+	expanded := map[string]Task{}
+	for name, task := range tasks {
+		if len(task.Matrix) == 0 {
+			expanded[name] = task
+			continue
+		}
+		var members []string
+		for _, combination := range matrixCombinations(task.Matrix) {
+			var values []string
+			for _, key := range matrixKeys(task.Matrix) {
+				values = append(values, combination[key])
+			}
+			member := expansionName(name, values...)
+			expanded[member] = expandTask(name, task, combination, nil)
+			members = append(members, member)
+		}
+		expanded[name] = groupTask(members)
+	}
+	return expanded
+}
+
+// forEachBatch is the number of items of a for_each task that are run
+// together. The items are read in batches, so the database is not held open
+// while the runs add items.
+const forEachBatch = 100
+
+// runForEach runs a for_each task once for every item selected when the task
+// starts, so items added by the tasks it requires are included, but not items
+// added by the runs. The runs are recorded as <task>[<uid>] and share the
+// resource limits of the workflow.
+func (r *run) runForEach(ctx context.Context, taskName string, task Task) error {
+	rowIDs, err := maxRowIDs(r.workingDir)
+	if err != nil {
+		return fmt.Errorf("for_each: %s", err)
+	}
+	until := rowIDs[task.ForEach.Type]
+	if until == 0 {
+		return nil
+	}
+
+	var diags tfdiags.Diagnostics
+	var after int64
+	for ctx.Err() == nil {
+		items, last, err := selectBatch(r.workingDir, task.ForEach, after, until)
+		if err != nil {
+			return fmt.Errorf("for_each: %s", err)
+		}
+		if len(items) == 0 {
+			break
+		}
+		after = last
+		if err := r.runItems(ctx, taskName, task, items); err != nil {
+			diags = diags.Append(err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		diags = diags.Append(err)
+	}
+	return diags.Err()
+}
+
+// selectBatch reads the next forEachBatch items after the rowid after. It
+// returns the rowid of the last item.
+func selectBatch(storeDir string, forEach *ForEach, after, until int64) ([]gostore.Item, int64, error) {
+	it, err := SelectIterator(storeDir, forEach.Type, forEach.Filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer it.Close()
+	it.after, it.until = after, until
+
+	var items []gostore.Item
+	for len(items) < forEachBatch && it.Next() {
+		items = append(items, it.Item())
+	}
+	return items, it.rowID, it.Err()
+}
+
+// runItems runs a for_each task for a batch of items.
+func (r *run) runItems(ctx context.Context, taskName string, task Task, items []gostore.Item) error {
+	tasks := map[string]Task{}
+	for _, item := range items {
+		member := expandTask(taskName, task, nil, item)
+		member.Requires = nil
+		tasks[expansionName(taskName, stringValue(item["uid"]))] = member
+	}
+	members := *r.workflow
+	members.Tasks = tasks
+	members.graph = taskGraph(tasks)
+
+	// the members can use the outputs of the tasks required by the task
+	r.mutex.Lock()
+	results := Results{}
+	for name, result := range r.results {
+		results[name] = result
+	}
+	r.mutex.Unlock()
+
+	child := &run{
+		workflow:   &members,
+		workingDir: r.workingDir,
+		pluginDir:  r.pluginDir,
+		plugins:    r.plugins,
+		arguments:  r.arguments,
+		vars:       r.vars,
+		secrets:    r.secrets,
+		journal:    r.journal,
+		id:         r.id,
+		images:     r.images,
+		prefix:     r.prefix,
+		results:    results,
+		resumed:    map[string]bool{},
+		blocking:   map[string]bool{},
+	}
+	results, err := child.walk(ctx)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for name := range tasks {
+		r.results[name] = results[name]
+	}
+	return err
+}
+
+func expansionName(name string, values ...string) string {
+	return name + "[" + strings.Join(values, ",") + "]"
+}
+
+// expandTask creates the task for a combination of matrix values or an item.
+// The matrix values are added to the arguments and the filter is restricted to
+// the item. All ${{ matrix.<key> }} and ${{ item.<attribute> }} expressions are
+// replaced, item values in the command are quoted for sh.
+func expandTask(name string, task Task, combination map[string]string, item gostore.Item) Task {
+	// values of items come from the evidence and are quoted in the command
+	resolve := func(quote bool) func(expression string) (string, error) {
+		return func(expression string) (string, error) {
+			switch {
+			case strings.HasPrefix(expression, "matrix."):
+				if value, ok := combination[strings.TrimPrefix(expression, "matrix.")]; ok {
+					return value, nil
+				}
+			case strings.HasPrefix(expression, "item.") && item != nil:
+				value, _ := lookup(item, strings.TrimPrefix(expression, "item."))
+				if quote {
+					return shellJoin([]string{outputValue(value)}), nil
+				}
+				return outputValue(value), nil
+			}
+			// keep other expressions for the run
+			return "${{ " + expression + " }}", nil
+		}
+	}
+	expandString := func(s string) string {
+		expanded, _ := expand(s, resolve(false))
+		return expanded
+	}
+
+	task.Matrix = nil
+	if item != nil {
+		task.ForEach = nil
+	}
+	task.Command, _ = expand(task.Command, resolve(true))
+
+	arguments := Arguments{}
+	for key, value := range combination {
+		arguments[key] = value
+	}
+	for key, value := range task.Arguments {
+		arguments[key] = expandString(value)
+	}
+	task.Arguments = arguments
+
+	var filter Filter
+	for _, conditions := range task.Filter {
+		expandedConditions := map[string]string{}
+		for attribute, value := range conditions {
+			expandedConditions[attribute] = expandString(value)
+		}
+		filter = append(filter, expandedConditions)
+	}
+	if item != nil {
+		if filter == nil {
+			filter = Filter{{}}
+		}
+		filter = filter.And(map[string]string{"uid": "eq:" + stringValue(item["uid"])})
+	}
+	task.Filter = filter
+
+	// all expansions share the resource limit of the task
+	if task.Resources.Class == "" && task.Resources.MaxConcurrent > 0 {
+		task.Resources.Class = name
+	}
+	return task
+}
+
+// groupTask creates the task that requires all expansions of a task.
+func groupTask(members []string) Task {
+	sort.Strings(members)
+	return Task{Type: typeGroup, Requires: members}
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/forensicanalysis/forensicstore/goforensicstore"
+	"github.com/forensicanalysis/forensicstore/gostore"
+)
+
+func Test_expandMatrices(t *testing.T) {
+	tasks := expandMatrices(map[string]Task{
+		"hash": {
+			Type:      "plugin",
+			Command:   "hash ${{ matrix.algorithm }}",
+			Arguments: Arguments{"out": "${{ matrix.algorithm }}-${{ matrix.size }}.txt", "size": "1"},
+			Matrix:    map[string][]string{"algorithm": {"md5", "sha1"}, "size": {"small", "large"}},
+			Resources: Resources{MaxConcurrent: 1},
+		},
+		"report": {Type: "bash", Command: "true", Requires: []string{"hash"}},
+	})
+
+	var names []string
+	for name := range tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := []string{"hash", "hash[md5,large]", "hash[md5,small]", "hash[sha1,large]", "hash[sha1,small]", "report"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("expandMatrices() tasks = %v, want %v", names, want)
+	}
+
+	if group := tasks["hash"]; group.Type != typeGroup || len(group.Requires) != 4 {
+		t.Errorf("group task = %+v", group)
+	}
+	member := tasks["hash[sha1,small]"]
+	if member.Command != "hash sha1" {
+		t.Errorf("command = %s, want hash sha1", member.Command)
+	}
+	wantArguments := Arguments{"algorithm": "sha1", "size": "1", "out": "sha1-small.txt"}
+	if !reflect.DeepEqual(member.Arguments, wantArguments) {
+		t.Errorf("arguments = %v, want %v", member.Arguments, wantArguments)
+	}
+	if member.Matrix != nil || member.Resources.Class != "hash" {
+		t.Errorf("member = %+v", member)
+	}
+}
+
+func TestWorkflow_RunMatrix(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggymatrix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+	store, err := goforensicstore.NewJSONLite(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.pf", "b.pf", "c.evtx"} {
+		if _, err := store.Insert(map[string]interface{}{"type": "element", "name": name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	workflow := Workflow{Tasks: map[string]Task{
+		"touch": {
+			Type:    "bash",
+			Command: "touch ${{ matrix.name }}.txt",
+			Matrix:  map[string][]string{"name": {"x", "y"}},
+		},
+		"each": {
+			Type:     "bash",
+			Command:  "touch ${{ item.name }}.done",
+			ForEach:  &ForEach{Type: "element", Filter: Filter{{"name": "suffix:.pf"}}},
+			Requires: []string{"touch"},
+		},
+		"report": {Type: "bash", Command: "ls *.txt *.done > report", Requires: []string{"each"}},
+	}}
+	workflow.SetupGraph()
+
+	results, err := workflow.Run(context.Background(), storeDir, "", nil, Arguments{})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for name, result := range results {
+		if result.Status != StatusSucceeded {
+			t.Errorf("task %s: status = %s, want %s", name, result.Status, StatusSucceeded)
+		}
+	}
+	if len(results) != 7 {
+		t.Errorf("Run() returned %d results, want 7", len(results))
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(storeDir, "report"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a.pf.done\nb.pf.done\nx.txt\ny.txt\n"; string(b) != want {
+		t.Errorf("report = %q, want %q", b, want)
+	}
+}
+
+// insertPlugin adds elements with the given names to the forensicstore.
+type insertPlugin struct{ names []string }
+
+func (*insertPlugin) Description() string {
+	return ""
+}
+
+func (p *insertPlugin) Run(_ context.Context, storeDir string, _ Arguments, _ Filter) error {
+	store, err := goforensicstore.NewJSONLite(storeDir)
+	if err != nil {
+		return err
+	}
+	for _, name := range p.names {
+		if _, err := store.Insert(map[string]interface{}{"type": "element", "name": name}); err != nil {
+			return err
+		}
+	}
+	return store.Close()
+}
+
+func TestWorkflow_RunForEachUpstreamItems(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggyforeach")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	workflow := Workflow{Tasks: map[string]Task{
+		"insert": {Type: "plugin", Command: "insert"},
+		"each": {
+			Type:     "bash",
+			Command:  "touch ${{ item.name }}.done",
+			ForEach:  &ForEach{Type: "element"},
+			Requires: []string{"insert"},
+		},
+	}}
+	workflow.SetupGraph()
+
+	plugins := map[string]Plugin{"insert": &insertPlugin{names: []string{"a", "b"}}}
+	results, err := workflow.Run(context.Background(), storeDir, "", plugins, Arguments{})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(results) != 4 {
+		t.Errorf("Run() returned %d results, want 4", len(results))
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, err := os.Stat(filepath.Join(storeDir, name+".done")); err != nil {
+			t.Errorf("item %s was not processed: %s", name, err)
+		}
+	}
+}
+
+func TestWorkflow_RunForEachQuotesItems(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggyforeach")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	name := "it's; touch injected x"
+	workflow := Workflow{Tasks: map[string]Task{
+		"insert": {Type: "plugin", Command: "insert"},
+		"each": {
+			Type:     "bash",
+			Command:  "touch ${{ item.name }}.done",
+			ForEach:  &ForEach{Type: "element"},
+			Requires: []string{"insert"},
+		},
+	}}
+	workflow.SetupGraph()
+
+	plugins := map[string]Plugin{"insert": &insertPlugin{names: []string{name}}}
+	if _, err := workflow.Run(context.Background(), storeDir, "", plugins, Arguments{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, name+".done")); err != nil {
+		t.Errorf("item was not processed: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "injected")); err == nil {
+		t.Error("item name was run as command")
+	}
+}
+
+func Test_expandTaskDockerCommand(t *testing.T) {
+	name := "it's; rm x"
+	task := expandTask("each", Task{Type: "docker", Command: "cat ${{ item.name }}"}, nil, gostore.Item{"uid": "element--1", "name": name})
+	cmd, _ := dockerArgs(task.Command, nil, nil, true, &run{})
+	if want := []string{"cat", name}; !reflect.DeepEqual(cmd[:2], want) {
+		t.Errorf("dockerArgs() = %q, want %q", cmd, want)
+	}
+}
+
+func Test_selectBatch(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggyforeach")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	var names []string
+	for i := 0; i < 2*forEachBatch+10; i++ {
+		names = append(names, fmt.Sprint(i))
+	}
+	if err := (&insertPlugin{names: names}).Run(context.Background(), storeDir, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	rowIDs, err := maxRowIDs(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// items added after the task started are not selected
+	if err := (&insertPlugin{names: []string{"late"}}).Run(context.Background(), storeDir, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	forEach := &ForEach{Type: "element", Filter: Filter{{"name": "%"}}}
+	seen := map[string]bool{}
+	var after int64
+	batches := 0
+	for {
+		items, last, err := selectBatch(storeDir, forEach, after, rowIDs["element"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) == 0 {
+			break
+		}
+		batches++
+		after = last
+		for _, item := range items {
+			seen[stringValue(item["name"])] = true
+			if _, ok := item[rowIDColumn]; ok {
+				t.Fatal("item contains the rowid")
+			}
+		}
+	}
+	if len(seen) != len(names) || seen["late"] || batches != 3 {
+		t.Errorf("selected %d items in %d batches, want %d in 3", len(seen), batches, len(names))
+	}
+}
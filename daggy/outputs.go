@@ -0,0 +1,192 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Sources of task outputs.
+const (
+	OutputFromStdout = "stdout"
+	OutputFromFile   = "file"
+	OutputFromPlugin = "plugin"
+)
+
+// An Output declares a value that a task passes to the tasks that require it.
+// The value is taken from a JSON object printed on stdout, from a file in the
+// forensicstore or from the outputs returned by an OutputPlugin. If From is
+// not set, the output is read from Path if it is set, from the plugin if it
+// returned outputs and from stdout otherwise.
+type Output struct {
+	From string `yaml:"from"`
+	Path string `yaml:"path"` // file
+	Key  string `yaml:"key"`  // defaults to the name of the output
+}
+
+// Outputs are the values that a task passes to later tasks.
+type Outputs map[string]string
+
+// An OutputPlugin is a plugin that returns outputs. It is run with
+// RunOutputs instead of Run.
+type OutputPlugin interface {
+	Plugin
+	RunOutputs(ctx context.Context, store string, args Arguments, filter Filter) (Outputs, error)
+}
+
+func (o Output) source(out *output) string {
+	switch {
+	case o.From != "":
+		return o.From
+	case o.Path != "":
+		return OutputFromFile
+	case out.pluginOutputs != nil:
+		return OutputFromPlugin
+	default:
+		return OutputFromStdout
+	}
+}
+
+// collectOutputs reads the declared outputs of a task after it ran. All
+// declared outputs must be present.
+func collectOutputs(task Task, workingDir string, out *output) (Outputs, error) {
+	if len(task.Outputs) == 0 {
+		return nil, nil
+	}
+
+	outputs := Outputs{}
+	var stdout map[string]interface{}
+	for _, name := range sortedOutputNames(task.Outputs) {
+		o := task.Outputs[name]
+		key := o.Key
+		if key == "" {
+			key = name
+		}
+
+		switch o.source(out) {
+		case OutputFromPlugin:
+			value, ok := out.pluginOutputs[key]
+			if !ok {
+				return nil, fmt.Errorf("output %s: plugin returned no %s", name, key)
+			}
+			outputs[name] = value
+		case OutputFromStdout:
+			if stdout == nil {
+				var ok bool
+				if stdout, ok = jsonObject(out.stdout.String()); !ok {
+					return nil, fmt.Errorf("output %s: stdout contains no json object", name)
+				}
+			}
+			value, ok := lookup(stdout, key)
+			if !ok {
+				return nil, fmt.Errorf("output %s: stdout contains no %s", name, key)
+			}
+			outputs[name] = outputValue(value)
+		case OutputFromFile:
+			path := o.Path
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(workingDir, path)
+			}
+			b, err := ioutil.ReadFile(path) // #nosec
+			if err != nil {
+				return nil, fmt.Errorf("output %s: %s", name, err)
+			}
+			if o.Key == "" {
+				outputs[name] = strings.TrimSpace(string(b))
+				continue
+			}
+			object, ok := jsonObject(string(b))
+			if !ok {
+				return nil, fmt.Errorf("output %s: %s contains no json object", name, o.Path)
+			}
+			value, ok := lookup(object, key)
+			if !ok {
+				return nil, fmt.Errorf("output %s: %s contains no %s", name, o.Path, key)
+			}
+			outputs[name] = outputValue(value)
+		default:
+			return nil, fmt.Errorf("output %s: unknown source `%s`", name, o.From)
+		}
+	}
+	return outputs, nil
+}
+
+func sortedOutputNames(outputs map[string]Output) []string {
+	var names []string
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// jsonObject parses s as a JSON object. If s contains other text, the last line
+// that is a JSON object is used, so scripts can print log messages before.
+func jsonObject(s string) (map[string]interface{}, bool) {
+	if object, ok := parseObject(s); ok {
+		return object, true
+	}
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	scanner.Buffer(nil, maxOutput)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		if object, ok := parseObject(lines[i]); ok {
+			return object, true
+		}
+	}
+	return nil, false
+}
+
+func parseObject(s string) (map[string]interface{}, bool) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") {
+		return nil, false
+	}
+	var object map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil || decoder.More() {
+		return nil, false
+	}
+	return object, true
+}
+
+// outputValue converts a JSON value into the string that replaces a reference
+// to the output. Objects and arrays are passed as JSON.
+func outputValue(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(value)
+		return string(b)
+	}
+	return stringValue(value)
+}
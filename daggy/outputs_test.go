@@ -0,0 +1,101 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type countPlugin struct{}
+
+func (*countPlugin) Description() string { return "count" }
+
+func (*countPlugin) Run(context.Context, string, Arguments, Filter) error { return nil }
+
+func (*countPlugin) RunOutputs(_ context.Context, _ string, args Arguments, _ Filter) (Outputs, error) {
+	return Outputs{"count": args.Get("count") + "1"}, nil
+}
+
+func TestWorkflow_RunOutputs(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggyoutputs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	workflow := Workflow{Tasks: map[string]Task{
+		"stdout": {Type: "bash", Command: `echo log; echo '{"count": 42, "keys": {"path": "a b; touch injected"}}'`, Outputs: map[string]Output{
+			"count": {},
+			"path":  {Key: "keys.path"},
+		}},
+		"file": {Type: "bash", Command: "echo ' result.csv ' > out.txt", Outputs: map[string]Output{
+			"file": {From: OutputFromFile, Path: "out.txt"},
+		}},
+		"plugin": {Type: "plugin", Command: "count", Requires: []string{"stdout"}, Arguments: Arguments{
+			"count": "${{ tasks.stdout.outputs.count }}",
+		}, Outputs: map[string]Output{"count": {}}},
+		"consumer": {Type: "bash", Requires: []string{"plugin", "file", "stdout"}, Command: `printf '{"line": "%s %s %s %s"}' ${{tasks.plugin.outputs.count}} ${{ tasks.file.outputs.file }} ${{ tasks.stdout.outputs.path }} "$1"`, Arguments: Arguments{
+			"arg": "${{ tasks.file.outputs.file }}",
+		}, Outputs: map[string]Output{"line": {}}},
+		"missing": {Type: "bash", Command: "echo no json", Outputs: map[string]Output{"count": {}}},
+	}}
+	workflow.SetupGraph()
+
+	results, _ := workflow.Run(context.Background(), storeDir, "", map[string]Plugin{"count": &countPlugin{}}, nil)
+	tests := []struct {
+		task, output, want string
+	}{
+		{"stdout", "count", "42"},
+		{"stdout", "path", "a b; touch injected"},
+		{"file", "file", "result.csv"},
+		{"plugin", "count", "421"},
+		{"consumer", "line", "421 result.csv a b; touch injected result.csv"},
+	}
+	for _, tt := range tests {
+		if results[tt.task].Status != StatusSucceeded {
+			t.Fatalf("task %s: status = %s: %s", tt.task, results[tt.task].Status, results[tt.task].Reason)
+		}
+		if got := results[tt.task].Outputs[tt.output]; got != tt.want {
+			t.Errorf("task %s: output %s = %q, want %q", tt.task, tt.output, got, tt.want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "injected")); err == nil {
+		t.Error("output was run as command")
+	}
+	if results["missing"].Status != StatusFailed {
+		t.Errorf("task missing: status = %s, want failed", results["missing"].Status)
+	}
+
+	// resumed tasks provide the outputs of the last run
+	workflow.Resume = true
+	results, err = workflow.Run(context.Background(), storeDir, "", map[string]Plugin{"count": &countPlugin{}}, nil)
+	if err == nil {
+		t.Fatal("Run() succeeded with failing task")
+	}
+	if results["consumer"].Reason != "succeeded before" || results["consumer"].Outputs["line"] != "421 result.csv a b; touch injected result.csv" {
+		t.Errorf("resumed consumer = %+v", results["consumer"])
+	}
+}
@@ -24,31 +24,115 @@ package daggy
 import (
 	"io/ioutil"
 	"log"
+	"strconv"
+	"strings"
 
 	"github.com/hashicorp/logutils"
-	"gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
-// Parse reads a workflow file.
+// Parse reads a workflow file and checks the task definitions. The tasks of
+// included workflow files are added to the workflow and the workflows of tasks
+// with the type workflow are parsed as well.
 func Parse(workflowFile string) (*Workflow, error) {
+	workflow, err := readWorkflow(workflowFile, nil)
+	if err != nil {
+		return nil, err
+	}
+	if errs := workflow.validateTasks(); len(errs) > 0 {
+		return nil, errs
+	}
+	return workflow, nil
+}
+
+// readWorkflow parses a workflow file and resolves its includes and nested
+// workflows. parents contains the files that include or nest workflowFile.
+func readWorkflow(workflowFile string, parents []string) (*Workflow, error) {
 	// parse the yaml definition
 	data, err := ioutil.ReadFile(workflowFile) // #nosec
 	if err != nil {
 		return nil, err
 	}
+	var document yaml.Node
+	err = yaml.Unmarshal(data, &document)
+	if err != nil {
+		return nil, err
+	}
 	workflow := Workflow{}
-	err = yaml.Unmarshal(data, &workflow)
+	err = document.Decode(&workflow)
 	if err != nil {
 		return nil, err
 	}
+
+	workflow.source = &source{file: workflowFile, positions: map[string]position{}, tasks: map[string]*source{}}
+	collectPositions(&document, "", workflow.source.positions)
+
+	parents = append(parents, workflowFile)
+	if err := workflow.resolveIncludes(parents); err != nil {
+		return nil, err
+	}
+	if err := workflow.resolveNested(parents); err != nil {
+		return nil, err
+	}
 	return &workflow, nil
 }
 
+// source records where a workflow was read from, so validation errors can
+// point to the offending lines. Tasks of included files have their own source.
+type source struct {
+	file      string
+	positions map[string]position
+	tasks     map[string]*source
+}
+
+type position struct {
+	line   int
+	column int
+}
+
+// collectPositions stores the position of every node below node. Keys are the
+// dotted path to the node, e.g. "tasks.prefetch.requires.0".
+func collectPositions(node *yaml.Node, path string, positions map[string]position) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			collectPositions(child, path, positions)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			childPath := joinPath(path, key.Value)
+			positions[childPath] = position{key.Line, key.Column}
+			collectPositions(value, childPath, positions)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			childPath := joinPath(path, strconv.Itoa(i))
+			positions[childPath] = position{child.Line, child.Column}
+			collectPositions(child, childPath, positions)
+		}
+	}
+}
+
+func joinPath(elements ...string) string {
+	var parts []string
+	for _, element := range elements {
+		if element != "" {
+			parts = append(parts, element)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
 func setupLogging() {
-	// disable logging in github.com/hashicorp/terraform/dag
-	log.SetOutput(&logutils.LevelFilter{
+	if _, ok := log.Writer().(*redactWriter); ok {
+		return
+	}
+	// disable logging in github.com/hashicorp/terraform/dag and remove
+	// secrets from all messages
+	log.SetOutput(&redactWriter{writer: &logutils.LevelFilter{
 		Levels:   []logutils.LogLevel{"TRACE", "OTHER"},
 		MinLevel: "OTHER",
 		Writer:   log.Writer(),
-	})
+	}})
 }
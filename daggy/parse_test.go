@@ -69,6 +69,7 @@ func TestParse(t *testing.T) {
 			}
 
 			got.graph = nil
+			got.source = nil
 
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Parse() got = %#v, want %v", got, tt.want)
@@ -81,3 +82,24 @@ func Test_setupLogging(t *testing.T) {
 	setupLogging()
 	log.Print("test")
 }
+
+func TestParse_builtinWorkflows(t *testing.T) {
+	for _, name := range []string{"persistence", "timeline", "windows-triage"} {
+		t.Run(name, func(t *testing.T) {
+			workflow, err := Parse("../workflows/" + name + ".yml")
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if workflow.Description == "" || len(workflow.Tasks) == 0 {
+				t.Errorf("Parse() got = %#v", workflow)
+			}
+		})
+	}
+	workflow, err := Parse("../workflows/windows-triage.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := workflow.Tasks["prefetch_report"]; !ok {
+		t.Error("windows-triage does not include timeline")
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A Plan describes how a workflow would be executed on a forensicstore.
+type Plan struct {
+	Store string
+	// Levels contains the tasks in execution order. All tasks of a level only
+	// require tasks of previous levels and can run in parallel.
+	Levels [][]*PlannedTask
+}
+
+// A PlannedTask is a task of a plan with its resolved command line.
+type PlannedTask struct {
+	Name    string
+	Type    string
+	Source  string // built-in plugin, script, docker image or dockerfile
+	Command []string
+	When    string // condition evaluated when the task would start
+	Error   string
+}
+
+// Plan resolves the execution order and the command lines of all tasks
+// without executing them or accessing the forensicstore. Outputs of other tasks
+// are shown as ${{ tasks.<task>.outputs.<name> }}.
+func (workflow *Workflow) Plan(workingDir, pluginDir string, plugins map[string]Plugin, arguments Arguments) *Plan {
+	r := &run{workflow: workflow, workingDir: workingDir, pluginDir: pluginDir, plugins: plugins, arguments: dropRegistryArguments(arguments)}
+	if b, err := workflow.bind(arguments); err == nil {
+		r.arguments, r.vars = b.arguments, b.vars
+	}
+
+	plan := &Plan{Store: workingDir}
+	levels := map[string]int{}
+	for _, name := range workflow.taskNames() {
+		level := workflow.level(name, levels, map[string]bool{})
+		for len(plan.Levels) <= level {
+			plan.Levels = append(plan.Levels, nil)
+		}
+		plan.Levels[level] = append(plan.Levels[level], r.plan(name))
+	}
+	return plan
+}
+
+// level returns the length of the longest requirement chain of a task.
+func (workflow *Workflow) level(name string, levels map[string]int, visiting map[string]bool) int {
+	if level, ok := levels[name]; ok {
+		return level
+	}
+	visiting[name] = true
+	level := 0
+	for _, requirement := range workflow.Tasks[name].Requires {
+		if _, ok := workflow.Tasks[requirement]; !ok || visiting[requirement] {
+			continue
+		}
+		if requirementLevel := workflow.level(requirement, levels, visiting) + 1; requirementLevel > level {
+			level = requirementLevel
+		}
+	}
+	visiting[name] = false
+	levels[name] = level
+	return level
+}
+
+func (r *run) plan(name string) *PlannedTask {
+	task := r.workflow.Tasks[name]
+	planned := &PlannedTask{Name: name, Type: task.Type, When: task.When}
+	task, err := resolveTask(task, r.planValue, r.planValue)
+	if err != nil {
+		planned.Error = err.Error()
+		return planned
+	}
+	switch task.Type {
+	case "bash":
+		planned.Command = append([]string{"sh"}, bashArgs(task.Command, task.Arguments, task.Filter, r)...)
+	case "plugin":
+		if _, ok := r.plugins[task.Command]; ok {
+			planned.Source = "built-in"
+			// built-in plugins only get the arguments of the task
+			planned.Command = []string{task.Command}
+			planned.Command = append(planned.Command, task.Arguments.toCommandline()...)
+			planned.Command = append(planned.Command, task.Filter.toCommandline()...)
+			break
+		}
+		command, err := scriptCommand(r.pluginDir, task.Command)
+		if err != nil {
+			planned.Error = err.Error()
+			break
+		}
+		scriptPath, _ := findScript(r.pluginDir, strings.Split(task.Command, " ")[0])
+		planned.Source = "script " + scriptPath
+		planned.Command = append([]string{"sh"}, bashArgs(command, task.Arguments, task.Filter, r)...)
+	case "docker":
+		planned.Source = "image " + task.Image
+		planned.Command = dockerRunCommand(task.Image, task.Command, task.Arguments, task.Filter, r.containerOptions(task), r)
+	case typeWorkflow:
+		planned.Source = "workflow " + task.Workflow
+	case typeGroup:
+		planned.Source = "requires " + strings.Join(task.Requires, ", ")
+	case "dockerfile":
+		planned.Source = "dockerfile " + filepath.Join(r.pluginDir, task.Dockerfile, "Dockerfile")
+		planned.Command = dockerRunCommand(dockerfileImage(task.Dockerfile), "", task.Arguments, task.Filter, r.containerOptions(task), r)
+	default:
+		planned.Error = fmt.Sprintf("unknown type `%s`", task.Type)
+	}
+	return planned
+}
+
+// dockerRunCommand returns a docker run command line equivalent to the
+// container created for a task.
+func dockerRunCommand(image, command string, arguments Arguments, filter Filter, opts containerOptions, r *run) []string {
+	c := opts.container
+	cmd, mounts := dockerArgs(command, arguments, filter, c.readOnlyStore(), r)
+	runCommand := []string{"docker", "run"}
+	if !opts.keep {
+		runCommand = append(runCommand, "--rm")
+	}
+	if c.Memory != "" {
+		runCommand = append(runCommand, "--memory", fmt.Sprint(c.memoryBytes()))
+	}
+	if c.CPUs > 0 {
+		runCommand = append(runCommand, "--cpus", fmt.Sprint(c.CPUs))
+	}
+	if c.Network != "" {
+		runCommand = append(runCommand, "--network", c.Network)
+	}
+	if c.User != "" {
+		runCommand = append(runCommand, "--user", c.User)
+	}
+	for _, env := range c.envList() {
+		runCommand = append(runCommand, "-e", env)
+	}
+	for _, name := range sortedKeys(opts.secrets) {
+		// docker takes the value from its environment
+		runCommand = append(runCommand, "-e", secretEnv(name))
+	}
+	for _, m := range mounts {
+		volume := m.Source + ":" + m.Target
+		if m.ReadOnly {
+			volume += ":ro"
+		}
+		runCommand = append(runCommand, "-v", volume)
+	}
+	runCommand = append(runCommand, "-w", "/store", image)
+	for _, arg := range cmd {
+		if arg != "" {
+			runCommand = append(runCommand, arg)
+		}
+	}
+	return runCommand
+}
+
+// Write prints the plan in a human readable form.
+func (p *Plan) Write(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "Plan for", p.Store); err != nil {
+		return err
+	}
+	for i, level := range p.Levels {
+		if _, err := fmt.Fprintf(w, "Level %d:\n", i+1); err != nil {
+			return err
+		}
+		for _, task := range level {
+			kind := task.Type
+			if task.Source != "" {
+				kind += ", " + task.Source
+			}
+			line := "    " + shellJoin(task.Command)
+			if task.Error != "" {
+				line = "    error: " + task.Error
+			}
+			if task.When != "" {
+				line = "    when: " + task.When + "\n" + line
+			}
+			if _, err := fmt.Fprintf(w, "  %s (%s)\n%s\n", task.Name, kind, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellJoin joins a command line and quotes arguments for sh.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if shellSafe.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellSplit splits a command line into its arguments like sh. Single and
+// double quotes and backslashes are removed, so values quoted by shellJoin
+// stay a single argument.
+func shellSplit(command string) []string {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, c := range command {
+		switch {
+		case escaped:
+			arg.WriteRune(c)
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(c)
+			}
+		case c == '\\' && (quote == 0 || quote == '"'):
+			escaped = true
+			inArg = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				arg.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWorkflow_Plan(t *testing.T) {
+	storeDir := filepath.Join(os.TempDir(), "daggyplan", "store")
+	workflow := &Workflow{Tasks: map[string]Task{
+		"list":    {Type: "bash", Command: "ls 'a b'"},
+		"builtin": {Type: "plugin", Command: "example", Requires: []string{"list"}, Filter: Filter{{"type": "file", "name": "foo"}}},
+		"missing": {Type: "plugin", Command: "missing"},
+		"docker":  {Type: "docker", Image: "alpine", Command: "echo hi", Requires: []string{"builtin", "missing"}},
+	}}
+	plugins := map[string]Plugin{"example": &ExamplePlugin{}}
+	plan := workflow.Plan(storeDir, "/plugins", plugins, Arguments{"foo": "bar"})
+
+	buf := &bytes.Buffer{}
+	if err := plan.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `Plan for ` + storeDir + `
+Level 1:
+  list (bash)
+    sh -c 'ls '\''a b'\''' --foo bar
+  missing (plugin)
+    error: no plugin or script ` + "`missing`" + ` found
+Level 2:
+  builtin (plugin, built-in)
+    example --filter name=foo,type=file
+Level 3:
+  docker (docker, image alpine)
+    docker run --rm -v ` + storeDir + `:/store -v /plugins:/plugins:ro -w /store alpine echo hi --foo bar
+`
+	if buf.String() != want {
+		t.Errorf("Plan() = \n%s\nwant\n%s", buf.String(), want)
+	}
+	if _, err := os.Stat(storeDir); !os.IsNotExist(err) {
+		t.Errorf("Plan() created store %s", storeDir)
+	}
+}
+
+func TestWorkflow_PlanTemplates(t *testing.T) {
+	if err := os.Setenv("DAGGY_TEST_HOME", "/home/test"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("DAGGY_TEST_HOME")
+
+	workflow := &Workflow{
+		Vars: map[string]Var{"case": {Required: true}},
+		Tasks: map[string]Task{
+			"count": {Type: "bash", Command: "echo ${{ vars.case }} ${{ env.DAGGY_TEST_HOME }}", Outputs: map[string]Output{"n": {}}},
+			"print": {Type: "bash", Command: "echo ${{ tasks.count.outputs.n }}", Requires: []string{"count"}},
+		},
+	}
+	plan := workflow.Plan("/store", "/plugins", nil, Arguments{"case": "42"})
+	want := [][]string{
+		{"sh", "-c", "echo 42 /home/test"},
+		{"sh", "-c", "echo ${{ tasks.count.outputs.n }}"},
+	}
+	for i, level := range plan.Levels {
+		if got := level[0].Command; !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("Plan() level %d command = %q, want %q", i+1, got, want[i])
+		}
+	}
+}
+
+func Test_shellSplit(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"", nil},
+		{"echo  a b", []string{"echo", "a", "b"}},
+		{shellJoin([]string{"cat", "it's; rm x", ""}), []string{"cat", "it's; rm x", ""}},
+		{`echo "a \"b\"" c\ d`, []string{"echo", `a "b"`, "c d"}},
+	}
+	for _, tt := range tests {
+		if got := shellSplit(tt.command); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shellSplit(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
@@ -22,6 +22,7 @@
 package daggy
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -30,33 +31,101 @@ import (
 
 // Plugin is an interface that all plugins need to implement.
 type Plugin interface {
-	Run(store string, args Arguments, filter Filter) error
+	Run(ctx context.Context, store string, args Arguments, filter Filter) error
 	Description() string
 }
 
-func plugin(command string, arguments Arguments, filter Filter, workflow *Workflow) error {
+// plugin runs a built-in plugin or a script. Built-in plugins get the secrets
+// with the arguments, scripts as environment variables.
+func plugin(ctx context.Context, command string, arguments Arguments, filter Filter, secrets map[string]string, r *run, out *output) error {
 	// try plugins
-	if plugin, ok := workflow.plugins[command]; ok {
-		return plugin.Run(workflow.workingDir, arguments, filter)
+	if plugin, ok := r.plugins[command]; ok {
+		if len(secrets) > 0 {
+			pluginArguments := Arguments{}
+			for name, value := range arguments {
+				pluginArguments[name] = value
+			}
+			for name, value := range secrets {
+				pluginArguments[name] = value
+			}
+			arguments = pluginArguments
+		}
+		if outputPlugin, ok := plugin.(OutputPlugin); ok {
+			outputs, err := outputPlugin.RunOutputs(ctx, r.workingDir, arguments, filter)
+			if outputs == nil {
+				outputs = Outputs{}
+			}
+			out.pluginOutputs = outputs
+			return err
+		}
+		return plugin.Run(ctx, r.workingDir, arguments, filter)
 	}
 
 	// try script
+	scriptCommand, err := scriptCommand(r.pluginDir, command)
+	if err != nil {
+		return err
+	}
+
+	return bash(ctx, scriptCommand, arguments, filter, secretEnvList(secrets), r, out)
+}
+
+// scriptCommand replaces the script name in command by its path in pluginDir.
+// Modules of python packages are run with python -m, so they can use relative
+// imports.
+func scriptCommand(pluginDir, command string) (string, error) {
 	parts := strings.Split(command, " ")
-	cmdPath := filepath.Join(workflow.pluginDir, parts[0])
-	info, err := os.Stat(cmdPath)
-	if os.IsNotExist(err) {
-		cmdPath = cmdPath + ".exe"
-		exeInfo, err := os.Stat(cmdPath)
+	cmdPath, err := findScript(pluginDir, parts[0])
+	if err != nil {
+		return "", err
+	}
+	if module, root, ok := pythonModule(cmdPath); ok {
+		pythonPath := shellJoin([]string{filepath.ToSlash(root)}) + `${PYTHONPATH:+"` + string(os.PathListSeparator) + `$PYTHONPATH"}`
+		cmdPath = "PYTHONPATH=" + pythonPath + " python -m " + module
+	}
+	return cmdPath + " " + strings.Join(parts[1:], " "), nil
+}
+
+// findScript returns the path of the script or executable name in pluginDir.
+// A python package name is found by its module name/name.py.
+func findScript(pluginDir, name string) (string, error) {
+	for _, cmdPath := range []string{filepath.Join(pluginDir, name), filepath.Join(pluginDir, name+".exe")} {
+		info, err := os.Stat(cmdPath)
 		if os.IsNotExist(err) {
-			return fmt.Errorf("no plugin or script `%s` found", command)
+			continue
 		}
-		if exeInfo.IsDir() {
-			return fmt.Errorf("script `%s.exe` is directory", cmdPath)
+		if err != nil {
+			return "", err
 		}
+		if info.IsDir() {
+			module := filepath.Join(cmdPath, name+".py")
+			if _, err := os.Stat(module); err == nil {
+				return module, nil
+			}
+			return "", fmt.Errorf("script `%s` is directory", cmdPath)
+		}
+		return cmdPath, nil
 	}
-	if info.IsDir() {
-		return fmt.Errorf("script `%s` is directory", cmdPath)
-	}
+	return "", fmt.Errorf("no plugin or script `%s` found", name)
+}
 
-	return bash(cmdPath+" "+strings.Join(parts[1:], " "), arguments, filter, workflow)
+// pythonModule returns the module name of a python file in a package and the
+// directory that contains the top level package.
+func pythonModule(path string) (module, root string, ok bool) {
+	if filepath.Ext(path) != ".py" {
+		return "", "", false
+	}
+	names := []string{strings.TrimSuffix(filepath.Base(path), ".py")}
+	root = filepath.Dir(path)
+	for {
+		if _, err := os.Stat(filepath.Join(root, "__init__.py")); err != nil {
+			break
+		}
+		names = append([]string{filepath.Base(root)}, names...)
+		root = filepath.Dir(root)
+	}
+	if len(names) == 1 {
+		return "", "", false
+	}
+	return strings.Join(names, "."), root, true
 }
@@ -0,0 +1,49 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_scriptCommand(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", "scripts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pluginDir := filepath.Join(root, "process")
+	root = filepath.ToSlash(filepath.Dir(root))
+
+	got, err := scriptCommand(pluginDir, "report prefetch prefetch.tmpl.j2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "PYTHONPATH=" + shellJoin([]string{root}) + `${PYTHONPATH:+"` + string(filepath.ListSeparator) + `$PYTHONPATH"} python -m scripts.process.report.report prefetch prefetch.tmpl.j2`
+	if got != want {
+		t.Errorf("scriptCommand() = %s, want %s", got, want)
+	}
+
+	if _, err := scriptCommand(pluginDir, "report/templates"); err == nil {
+		t.Error("scriptCommand() found directory without module")
+	}
+}
@@ -0,0 +1,439 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/forensicanalysis/forensicstore/goflatten"
+	"github.com/forensicanalysis/forensicstore/gostore"
+)
+
+// A query is a filter compiled into an SQL WHERE clause for a table of the
+// item database. Conditions that cannot be expressed in SQL are left out, so
+// the returned items need to be checked again if the query is not exact.
+type query struct {
+	where string
+	args  []interface{}
+	exact bool
+}
+
+// compile translates the filter for a table with the given column types. If
+// like is set, values without operator are LIKE patterns as in Select,
+// otherwise they must be contained as in Match.
+func (f Filter) compile(columns map[string]string, like bool) query {
+	if f == nil {
+		return query{where: "1", exact: true}
+	}
+	q := query{exact: true}
+	var ors []string
+	for _, conditions := range f {
+		var ands []string
+		for _, attribute := range sortedKeys(conditions) {
+			c, err := parseCondition(attribute, conditions[attribute])
+			if err != nil {
+				ands = append(ands, "0")
+				continue
+			}
+			where, args, exact := c.sql(columns, like)
+			ands = append(ands, where)
+			q.args = append(q.args, args...)
+			q.exact = q.exact && exact
+		}
+		if len(ands) == 0 {
+			ands = []string{"1"}
+		}
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	if len(ors) == 0 {
+		ors = []string{"0"}
+	}
+	q.where = strings.Join(ors, " OR ")
+	return q
+}
+
+// sql translates a condition into an SQL expression. It returns false if the
+// expression is only an approximation.
+func (c *condition) sql(columns map[string]string, like bool) (string, []interface{}, bool) {
+	columnType, ok := columns[c.attribute]
+	if !ok {
+		// the attribute is missing in all items of the table
+		if c.match(gostore.Item{}, like) {
+			return "1", nil, true
+		}
+		return "0", nil, true
+	}
+	column := quoteIdentifier(c.attribute)
+	text := strings.EqualFold(columnType, "TEXT")
+
+	var expr string
+	var args []interface{}
+	switch {
+	case c.operator == opPlain && like:
+		return column + " LIKE ?", []interface{}{c.value}, true
+	case c.operator == opPlain && text:
+		if strings.Contains(fmt.Sprint(nil), c.value) {
+			return fmt.Sprintf("(%s IS NULL OR instr(%s, ?) > 0)", column, column), []interface{}{c.value}, true
+		}
+		return fmt.Sprintf("instr(%s, ?) > 0", column), []interface{}{c.value}, true
+	case c.operator == opEq && text:
+		expr, args = column+" = ?", []interface{}{c.value}
+	case c.operator == opPrefix && text:
+		expr, args = fmt.Sprintf("substr(%s, 1, length(?)) = ?", column), []interface{}{c.value, c.value}
+	case c.operator == opSuffix && text && c.value != "":
+		expr, args = fmt.Sprintf("substr(%s, -length(?)) = ?", column), []interface{}{c.value, c.value}
+	case c.operator == opSuffix && text:
+		expr = "1"
+	case c.operator == opContains && text:
+		expr, args = fmt.Sprintf("instr(%s, ?) > 0", column), []interface{}{c.value}
+	case c.operator == opIn && text:
+		expr = fmt.Sprintf("%s IN (?%s)", column, strings.Repeat(", ?", len(c.values)-1))
+		for _, value := range c.values {
+			args = append(args, value)
+		}
+	case c.number != nil && !text:
+		comparators := map[string]string{opGt: ">", opGe: ">=", opLt: "<", opLe: "<="}
+		expr = fmt.Sprintf("(typeof(%s) IN ('integer', 'real') AND %s %s ?)", column, column, comparators[c.operator])
+		args = []interface{}{*c.number}
+	default:
+		return "1", nil, false
+	}
+
+	if c.negate {
+		return fmt.Sprintf("(%s IS NULL OR NOT (%s))", column, expr), args, true
+	}
+	return fmt.Sprintf("(%s IS NOT NULL AND %s)", column, expr), args, true
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// tableColumns returns the columns of a table and their types. It returns nil
+// if the table does not exist.
+func tableColumns(db *sql.DB, table string) (map[string]string, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info (%s)", quoteIdentifier(table))) // #nosec
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns map[string]string
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue interface{}
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		if columns == nil {
+			columns = map[string]string{}
+		}
+		columns[name] = columnType
+	}
+	return columns, rows.Err()
+}
+
+// An ItemIterator reads the items of a forensicstore one at a time, so large
+// stores can be processed in constant memory. Items are only read from the
+// database when Next is called. The iterator must be closed after use.
+//
+//	it, err := daggy.SelectIterator(storeDir, "file", filter)
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		item := it.Item()
+//		...
+//	}
+//	return it.Err()
+type ItemIterator struct {
+	db     *sql.DB
+	tables []string
+	filter Filter
+	like   bool
+
+	// only rows with after < rowid <= until are read if until is set
+	after, until int64
+	rowID        int64 // rowid of the current item
+
+	rows  *sql.Rows
+	cols  []string
+	exact bool
+	item  gostore.Item
+	err   error
+}
+
+// Next advances the iterator to the next item that matches the filter. It
+// returns false if there are no more items or an error occurred.
+func (it *ItemIterator) Next() bool {
+	it.item = nil
+	for it.err == nil {
+		if it.rows == nil {
+			if len(it.tables) == 0 {
+				return false
+			}
+			table := it.tables[0]
+			it.tables = it.tables[1:]
+			it.err = it.open(table)
+			continue
+		}
+
+		if !it.rows.Next() {
+			it.err = it.rows.Err()
+			it.rows.Close()
+			it.rows = nil
+			continue
+		}
+		item, err := scanItem(it.rows, it.cols)
+		if err != nil {
+			it.err = err
+			break
+		}
+		if it.until > 0 {
+			rowID, _ := item[rowIDColumn].(float64)
+			it.rowID = int64(rowID)
+			delete(item, rowIDColumn)
+		}
+		if it.exact || it.filter.match(item, it.like) {
+			it.item = item
+			return true
+		}
+	}
+	return false
+}
+
+// open starts reading the items of a table. Missing tables are skipped.
+func (it *ItemIterator) open(table string) error {
+	columns, err := tableColumns(it.db, table)
+	if err != nil {
+		return err
+	}
+	if columns == nil {
+		log.Printf("forensicstore contains no %s items", table)
+		return nil
+	}
+	q := it.filter.compile(columns, it.like)
+	statement := fmt.Sprintf("SELECT * FROM %s WHERE %s", quoteIdentifier(table), q.where)
+	if it.until > 0 {
+		statement = fmt.Sprintf("SELECT rowid AS %s, * FROM %s WHERE rowid > ? AND rowid <= ? AND (%s) ORDER BY rowid", rowIDColumn, quoteIdentifier(table), q.where)
+		q.args = append([]interface{}{it.after, it.until}, q.args...)
+	}
+	rows, err := it.db.Query(statement, q.args...) // #nosec
+	if err != nil {
+		return err
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return err
+	}
+	it.rows, it.cols, it.exact = rows, cols, q.exact
+	return nil
+}
+
+// rowIDColumn is the name of the rowid in the rows of a range.
+const rowIDColumn = "_rowid"
+
+// Item returns the current item.
+func (it *ItemIterator) Item() gostore.Item {
+	return it.item
+}
+
+// Err returns the first error that occurred while iterating.
+func (it *ItemIterator) Err() error {
+	return it.err
+}
+
+// Close releases the database of the iterator.
+func (it *ItemIterator) Close() error {
+	if it.rows != nil {
+		it.rows.Close()
+		it.rows = nil
+	}
+	return it.db.Close()
+}
+
+// collect reads all remaining items of the iterator.
+func (it *ItemIterator) collect() ([]gostore.Item, error) {
+	var items []gostore.Item
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	return items, it.Err()
+}
+
+// scanItem converts a row into an item like the forensicstore does.
+func scanItem(rows *sql.Rows, cols []string) (gostore.Item, error) {
+	values := make([]interface{}, len(cols))
+	pointers := make([]interface{}, len(cols))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+
+	flat := map[string]interface{}{}
+	for i, col := range cols {
+		switch value := values[i].(type) {
+		case nil:
+		case int64:
+			flat[col] = float64(value)
+		case []byte:
+			flat[col] = string(value)
+		default:
+			flat[col] = value
+		}
+	}
+	return goflatten.Unflatten(flat)
+}
+
+// SelectIterator returns an iterator over the items of a type in the
+// forensicstore in storeDir that match the filter. Values without operator are
+// LIKE patterns. The filter is evaluated in the database as far as possible. A
+// forensicstore without items of the type has no items, which is logged.
+func SelectIterator(storeDir, itemType string, filter Filter) (*ItemIterator, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	db, err := openItemDB(storeDir)
+	if err != nil {
+		return nil, err
+	}
+	return &ItemIterator{db: db, tables: []string{itemType}, filter: filter, like: true}, nil
+}
+
+// MatchIterator returns an iterator over all items in the forensicstore in
+// storeDir that match the filter as in Filter.Match. The filter is evaluated
+// in the database as far as possible.
+func MatchIterator(storeDir string, filter Filter) (*ItemIterator, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	db, err := openItemDB(storeDir)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := itemTables(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &ItemIterator{db: db, tables: tables, filter: filter}, nil
+}
+
+// countItemsMatching returns the number of items of a type in the
+// forensicstore in storeDir that match the filter as in Filter.Match. Missing
+// stores and tables contain no items.
+func countItemsMatching(storeDir, itemType string, filter Filter) (int, error) {
+	db, err := openItemDB(storeDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	columns, err := tableColumns(db, itemType)
+	if err != nil || columns == nil {
+		db.Close()
+		return 0, err
+	}
+	q := filter.compile(columns, false)
+	if q.exact {
+		defer db.Close()
+		var count int
+		err := db.QueryRow(fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", quoteIdentifier(itemType), q.where), q.args...).Scan(&count) // #nosec
+		return count, err
+	}
+
+	it := &ItemIterator{db: db, tables: []string{itemType}, filter: filter}
+	defer it.Close()
+	count := 0
+	for it.Next() {
+		count++
+	}
+	return count, it.Err()
+}
+
+// itemsDigest returns a hash of the items of the given types in the
+// forensicstore in storeDir that match the filter. A missing forensicstore has
+// no items.
+func itemsDigest(storeDir string, types []string, filter Filter) (string, error) {
+	h := sha256.New()
+	for _, itemType := range types {
+		it, err := SelectIterator(storeDir, itemType, filter)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\n", itemType)
+		for it.Next() {
+			b, err := json.Marshal(it.Item())
+			if err != nil {
+				it.Close()
+				return "", err
+			}
+			h.Write(append(b, '\n'))
+		}
+		err = it.Err()
+		it.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// SelectItems returns the items of a type in the forensicstore in storeDir
+// that match the filter like SelectIterator. All items are held in memory,
+// so SelectIterator should be preferred for large stores.
+func SelectItems(storeDir, itemType string, filter Filter) ([]gostore.Item, error) {
+	it, err := SelectIterator(storeDir, itemType, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	return it.collect()
+}
+
+// MatchItems returns all items in the forensicstore in storeDir that match
+// the filter like MatchIterator. All items are held in memory, so
+// MatchIterator should be preferred for large stores.
+func MatchItems(storeDir string, filter Filter) ([]gostore.Item, error) {
+	it, err := MatchIterator(storeDir, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	return it.collect()
+}
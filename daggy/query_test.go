@@ -0,0 +1,231 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/forensicanalysis/forensicstore/goforensicstore"
+	"github.com/forensicanalysis/forensicstore/gostore"
+)
+
+func TestSelectItems(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "daggyquery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	storeDir := filepath.Join(tempDir, "test.forensicstore")
+
+	items := []gostore.Item{
+		{"type": "element", "name": "System.evtx", "size": 2000, "path": `C:\Windows\System32`, "attributes": map[string]interface{}{"created": "2020-01-02T00:00:00Z"}},
+		{"type": "element", "name": "Security.EVTX", "size": 20, "path": `C:\Windows\System32`},
+		{"type": "element", "name": "a.pf", "size": 2000000, "path": `C:\Windows\Prefetch`, "attributes": map[string]interface{}{"created": "2019-01-02T00:00:00Z"}},
+		{"type": "element", "name": "it's.txt", "path": `D:\`},
+		{"type": "other", "name": "b.pf", "size": 5},
+	}
+	store, err := goforensicstore.NewJSONLite(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		if _, err := store.Insert(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := MatchItems(storeDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(items) {
+		t.Fatalf("MatchItems() returned %d items, want %d", len(all), len(items))
+	}
+
+	filters := map[string]Filter{
+		"none":           nil,
+		"empty":          {},
+		"like":           {{"name": "%.evtx"}},
+		"contains":       {{"name": "contains:evtx"}},
+		"eq":             {{"name": "eq:a.pf"}},
+		"quote":          {{"name": "eq:it's.txt"}},
+		"prefix":         {{"name": "prefix:Sec"}},
+		"suffix":         {{"name": "suffix:.pf"}},
+		"empty suffix":   {{"name": "suffix:"}},
+		"in":             {{"name": "in:a.pf|b.pf|System.evtx"}},
+		"regex":          {{"name": "regex:^[A-Z]"}},
+		"gt":             {{"size": "gt:1KB"}},
+		"le":             {{"size": "le:2000"}},
+		"negate":         {{"path": "!contains:System32"}},
+		"negate missing": {{"size": "!gt:100"}},
+		"nested":         {{"attributes.created": "ge:2020-01-01"}},
+		"missing column": {{"foo": "eq:bar"}},
+		"missing negate": {{"foo": "!eq:bar"}},
+		"plain missing":  {{"foo": "nil"}},
+		"or":             {{"name": "suffix:.pf"}, {"size": "lt:100"}},
+		"and":            {{"name": "suffix:.pf", "size": "gt:10"}},
+		"type":           {{"type": "other"}},
+	}
+	for name, filter := range filters {
+		t.Run(name, func(t *testing.T) {
+			for _, like := range []bool{true, false} {
+				var want []string
+				for _, item := range all {
+					if item["type"] == "element" && filter.match(item, like) {
+						want = append(want, item["name"].(string))
+					}
+				}
+				var got []gostore.Item
+				if like {
+					got, err = SelectItems(storeDir, "element", filter)
+				} else {
+					got, err = MatchItems(storeDir, filter)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				var gotNames []string
+				for _, item := range got {
+					if item["type"] == "element" {
+						gotNames = append(gotNames, item["name"].(string))
+					}
+				}
+				sort.Strings(want)
+				sort.Strings(gotNames)
+				if len(want) != len(gotNames) {
+					t.Fatalf("like %v: got %v, want %v", like, gotNames, want)
+				}
+				for i := range want {
+					if want[i] != gotNames[i] {
+						t.Errorf("like %v: got %v, want %v", like, gotNames, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFilter_compile(t *testing.T) {
+	columns := map[string]string{"name": "TEXT", "size": "INTEGER"}
+	tests := []struct {
+		name      string
+		filter    Filter
+		wantWhere string
+		wantExact bool
+	}{
+		{"like", Filter{{"name": "%.evtx"}}, `("name" LIKE ?)`, true},
+		{"negated eq", Filter{{"name": "!eq:a"}}, `(("name" IS NULL OR NOT ("name" = ?)))`, true},
+		{"regex", Filter{{"name": "regex:a"}}, `(1)`, false},
+		{"number", Filter{{"size": "gt:1KB"}}, `(("size" IS NOT NULL AND (typeof("size") IN ('integer', 'real') AND "size" > ?)))`, true},
+		{"missing", Filter{{"foo": "eq:a"}, {"foo": "!eq:a"}}, `(0) OR (1)`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.filter.compile(columns, true)
+			if q.where != tt.wantWhere || q.exact != tt.wantExact {
+				t.Errorf("compile() = %s (%v), want %s (%v)", q.where, q.exact, tt.wantWhere, tt.wantExact)
+			}
+		})
+	}
+}
+
+func TestItemIterator(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "daggyiterator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	storeDir := filepath.Join(tempDir, "test.forensicstore")
+
+	store, err := goforensicstore.NewJSONLite(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []gostore.Item{
+		{"type": "element", "name": "a.pf"},
+		{"type": "element", "name": "b.evtx"},
+		{"type": "other", "name": "c.pf"},
+		{"type": "third", "name": "d.txt"},
+	} {
+		if _, err := store.Insert(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		iterator func() (*ItemIterator, error)
+		want     int
+	}{
+		{"select", func() (*ItemIterator, error) { return SelectIterator(storeDir, "element", nil) }, 2},
+		{"select filter", func() (*ItemIterator, error) {
+			return SelectIterator(storeDir, "element", Filter{{"name": "suffix:.pf"}})
+		}, 1},
+		{"select missing table", func() (*ItemIterator, error) { return SelectIterator(storeDir, "missing", nil) }, 0},
+		{"match", func() (*ItemIterator, error) { return MatchIterator(storeDir, nil) }, 4},
+		{"match filter", func() (*ItemIterator, error) { return MatchIterator(storeDir, Filter{{"name": "regex:\\.pf$"}}) }, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it, err := tt.iterator()
+			if err != nil {
+				t.Fatal(err)
+			}
+			count := 0
+			for it.Next() {
+				if it.Item()["name"] == nil {
+					t.Errorf("Item() = %v, want name", it.Item())
+				}
+				count++
+			}
+			if err := it.Err(); err != nil {
+				t.Error(err)
+			}
+			if it.Next() || it.Item() != nil {
+				t.Error("Next() after the last item returned true")
+			}
+			if err := it.Close(); err != nil {
+				t.Error(err)
+			}
+			if count != tt.want {
+				t.Errorf("iterated %d items, want %d", count, tt.want)
+			}
+		})
+	}
+
+	if _, err := SelectIterator(storeDir, "element", Filter{{"size": "gt:abc"}}); err == nil {
+		t.Error("SelectIterator() with invalid filter returned no error")
+	}
+	if _, err := MatchIterator(filepath.Join(tempDir, "missing"), nil); err == nil {
+		t.Error("MatchIterator() on missing store returned no error")
+	}
+}
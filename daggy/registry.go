@@ -0,0 +1,246 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+)
+
+// defaultRegistry is the key of the Docker Hub in docker config files.
+const defaultRegistry = "https://index.docker.io/v1/"
+
+// dockerConfig contains the registry credentials of a docker config file.
+type dockerConfig struct {
+	Auths       map[string]dockerAuth `json:"auths"`
+	CredsStore  string                `json:"credsStore"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+}
+
+type dockerAuth struct {
+	Auth          string `json:"auth"` // base64 encoded user:password
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+	RegistryToken string `json:"registrytoken"`
+}
+
+// registryArguments are the arguments that older versions used for registry
+// credentials. Credentials are now taken from the docker config.
+var registryArguments = []string{"docker-server", "docker-user", "docker-password"}
+
+// dropRegistryArguments returns the arguments without the registry
+// credentials, so they are never passed to tasks.
+func dropRegistryArguments(arguments Arguments) Arguments {
+	dropped := Arguments{}
+	for name, value := range arguments {
+		dropped[name] = value
+	}
+	for _, name := range registryArguments {
+		if value, ok := dropped[name]; ok {
+			if name == "docker-password" {
+				addSecret(value)
+			}
+			log.Printf("%s is deprecated and ignored, use docker login for registry credentials", name)
+			delete(dropped, name)
+		}
+	}
+	return dropped
+}
+
+// loadDockerConfig reads $DOCKER_CONFIG/config.json or
+// ~/.docker/config.json. A missing file contains no credentials.
+func loadDockerConfig() (*dockerConfig, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return &dockerConfig{}, nil
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "config.json")) // #nosec
+	if os.IsNotExist(err) {
+		return &dockerConfig{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	config := &dockerConfig{}
+	if err := json.Unmarshal(b, config); err != nil {
+		return nil, fmt.Errorf("docker config %s: %s", filepath.Join(dir, "config.json"), err)
+	}
+	return config, nil
+}
+
+// registryServer returns the registry of an image as used in docker config
+// files.
+func registryServer(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", err
+	}
+	if domain := reference.Domain(named); domain != "docker.io" {
+		return domain, nil
+	}
+	return defaultRegistry, nil
+}
+
+// hostname strips the scheme and path of a registry server.
+func hostname(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	return strings.SplitN(server, "/", 2)[0]
+}
+
+// credentials returns the credentials for a registry. Credential helpers
+// configured for the registry or as credential store take precedence over the
+// auths of the config file.
+func (c *dockerConfig) credentials(ctx context.Context, server string) (types.AuthConfig, error) {
+	helper := c.CredsStore
+	for registry, registryHelper := range c.CredHelpers {
+		if hostname(registry) == hostname(server) {
+			helper = registryHelper
+		}
+	}
+	if helper != "" {
+		return credentialHelper(ctx, helper, server)
+	}
+
+	for registry, auth := range c.Auths {
+		if hostname(registry) != hostname(server) {
+			continue
+		}
+		authConfig := types.AuthConfig{
+			Username:      auth.Username,
+			Password:      auth.Password,
+			IdentityToken: auth.IdentityToken,
+			RegistryToken: auth.RegistryToken,
+			ServerAddress: server,
+		}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return types.AuthConfig{}, fmt.Errorf("invalid auth for %s: %s", registry, err)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return types.AuthConfig{}, fmt.Errorf("invalid auth for %s", registry)
+			}
+			authConfig.Username, authConfig.Password = parts[0], parts[1]
+		}
+		return authConfig, nil
+	}
+	return types.AuthConfig{ServerAddress: server}, nil
+}
+
+// credentialHelper gets the credentials for a registry from
+// docker-credential-<helper>.
+func credentialHelper(ctx context.Context, helper, server string) (types.AuthConfig, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker-credential-"+helper, "get") // #nosec
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stdout.String()+stderr.String(), "credentials not found") {
+			return types.AuthConfig{ServerAddress: server}, nil
+		}
+		return types.AuthConfig{}, fmt.Errorf("credential helper docker-credential-%s: %s %s", helper, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var credentials struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &credentials); err != nil {
+		return types.AuthConfig{}, fmt.Errorf("credential helper docker-credential-%s: %s", helper, err)
+	}
+	authConfig := types.AuthConfig{ServerAddress: server}
+	if credentials.Username == "<token>" {
+		authConfig.IdentityToken = credentials.Secret
+	} else {
+		authConfig.Username, authConfig.Password = credentials.Username, credentials.Secret
+	}
+	return authConfig, nil
+}
+
+// registryAuth returns the encoded credentials to pull an image or an empty
+// string if there are none.
+func registryAuth(ctx context.Context, image string) (string, error) {
+	server, err := registryServer(image)
+	if err != nil {
+		return "", err
+	}
+	config, err := loadDockerConfig()
+	if err != nil {
+		return "", err
+	}
+	authConfig, err := config.credentials(ctx, server)
+	if err != nil {
+		return "", err
+	}
+	if authConfig.Username == "" && authConfig.Password == "" && authConfig.IdentityToken == "" && authConfig.RegistryToken == "" {
+		return "", nil
+	}
+	b, err := json.Marshal(authConfig)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+// buildAuthConfigs returns the credentials of all registries in the docker
+// config, which are used to pull the base images of dockerfiles.
+func buildAuthConfigs(ctx context.Context) (map[string]types.AuthConfig, error) {
+	config, err := loadDockerConfig()
+	if err != nil {
+		return nil, err
+	}
+	servers := map[string]bool{}
+	for server := range config.Auths {
+		servers[server] = true
+	}
+	for server := range config.CredHelpers {
+		servers[server] = true
+	}
+	authConfigs := map[string]types.AuthConfig{}
+	for server := range servers {
+		authConfig, err := config.credentials(ctx, server)
+		if err != nil {
+			return nil, err
+		}
+		authConfigs[server] = authConfig
+	}
+	return authConfigs, nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestDockerConfig_credentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "daggyregistry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hubAuth := base64.StdEncoding.EncodeToString([]byte("hub:hubpass"))
+	config := `{"auths": {"https://index.docker.io/v1/": {"auth": "` + hubAuth + `"}, "https://registry.example.com": {"username": "user", "password": "pass"}}, "credHelpers": {"helper.example.com": "daggytest"}}`
+	helper := "#!/bin/sh\nread server\necho '{\"ServerURL\": \"'$server'\", \"Username\": \"<token>\", \"Secret\": \"token\"}'\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "docker-credential-daggytest"), []byte(helper), 0700); err != nil { // #nosec
+		t.Fatal(err)
+	}
+	defer os.Setenv("DOCKER_CONFIG", os.Getenv("DOCKER_CONFIG"))
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	if err := os.Setenv("DOCKER_CONFIG", dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		image string
+		want  types.AuthConfig
+	}{
+		{"docker hub", "alpine", types.AuthConfig{Username: "hub", Password: "hubpass", ServerAddress: defaultRegistry}},
+		{"auths", "registry.example.com/tools/plaso:latest", types.AuthConfig{Username: "user", Password: "pass", ServerAddress: "registry.example.com"}},
+		{"credential helper", "helper.example.com/plaso", types.AuthConfig{IdentityToken: "token", ServerAddress: "helper.example.com"}},
+		{"no credentials", "other.example.com/plaso", types.AuthConfig{ServerAddress: "other.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := loadDockerConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			server, err := registryServer(tt.image)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := config.credentials(context.Background(), server)
+			if err != nil {
+				t.Fatalf("credentials() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("credentials() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if auth, err := registryAuth(context.Background(), "other.example.com/plaso"); err != nil || auth != "" {
+		t.Errorf("registryAuth() = %q, %v, want no credentials", auth, err)
+	}
+}
+
+func TestWorkflow_PlanRegistryArguments(t *testing.T) {
+	workflow := Workflow{
+		Arguments: Arguments{"docker-server": "registry.example.com"},
+		Tasks:     map[string]Task{"list": {Type: "bash", Command: "ls"}},
+	}
+	workflow.SetupGraph()
+
+	plan := workflow.Plan("/store", "/plugins", nil, Arguments{"docker-user": "user", "docker-password": "hunter2", "case": "42"})
+	want := []string{"sh", "-c", "ls", "--case", "42"}
+	if got := plan.Levels[0][0].Command; !reflect.DeepEqual(got, want) {
+		t.Errorf("Plan() command = %q, want %q", got, want)
+	}
+	if got := redact("hunter2"); got == "hunter2" {
+		t.Error("docker-password is not redacted")
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Report collects the results of workflow runs on several forensicstores.
+type Report struct {
+	Stores []*StoreReport `json:"stores"`
+	mutex  sync.Mutex
+}
+
+// A StoreReport contains the results of a workflow run on a single
+// forensicstore.
+type StoreReport struct {
+	Store string        `json:"store"`
+	Error string        `json:"error,omitempty"`
+	Tasks []*TaskReport `json:"tasks"`
+}
+
+// A TaskReport is the result of a single task including its duration in
+// seconds.
+type TaskReport struct {
+	Name string `json:"name"`
+	*TaskResult
+	Duration float64 `json:"duration"`
+}
+
+// ReadReport reads a report in the json format.
+func ReadReport(r io.Reader) (*Report, error) {
+	report := &Report{}
+	if err := json.NewDecoder(r).Decode(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Results returns the result of every task of the store.
+func (s *StoreReport) Results() Results {
+	results := Results{}
+	for _, task := range s.Tasks {
+		if task.TaskResult != nil {
+			results[task.Name] = task.TaskResult
+		}
+	}
+	return results
+}
+
+// Add adds the results of a workflow run to the report.
+func (r *Report) Add(store string, results Results, err error) {
+	storeReport := &StoreReport{Store: store, Tasks: []*TaskReport{}}
+	if err != nil {
+		storeReport.Error = err.Error()
+	}
+
+	var names []string
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result := results[name]
+		duration := 0.0
+		if result.Start != nil && result.End != nil {
+			duration = result.End.Sub(*result.Start).Seconds()
+		}
+		storeReport.Tasks = append(storeReport.Tasks, &TaskReport{Name: name, TaskResult: result, Duration: duration})
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Stores = append(r.Stores, storeReport)
+}
+
+// Write writes the report in the given format, either json or junit.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		return r.WriteJSON(w)
+	case "junit":
+		return r.WriteJUnit(w)
+	default:
+		return fmt.Errorf("unknown report format `%s`", format)
+	}
+}
+
+// WriteJSON writes the report as json.
+func (r *Report) WriteJSON(w io.Writer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	Classname  string          `xml:"classname,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitMessage   `xml:"failure,omitempty"`
+	Error      *junitMessage   `xml:"error,omitempty"`
+	Skipped    *junitMessage   `xml:"skipped,omitempty"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+	SystemErr  string          `xml:"system-err,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML. Every forensicstore is a test
+// suite and every task a test case.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	suites := junitTestSuites{}
+	for _, store := range r.Stores {
+		suite := junitTestSuite{Name: store.Store}
+		totalTime := 0.0
+		for _, task := range store.Tasks {
+			testCase := junitTestCase{
+				Name:      task.Name,
+				Classname: store.Store,
+				Time:      fmt.Sprintf("%.3f", task.Duration),
+				Properties: []junitProperty{
+					{Name: "attempts", Value: fmt.Sprint(task.Attempts)},
+				},
+				SystemOut: task.Stdout,
+				SystemErr: task.Stderr,
+			}
+			if task.Items != nil {
+				testCase.Properties = append(testCase.Properties, junitProperty{Name: "items", Value: fmt.Sprint(*task.Items)})
+			}
+			message := strings.SplitN(task.Reason, "\n", 2)[0]
+			switch task.Status {
+			case StatusFailed:
+				testCase.Failure = &junitMessage{Message: message, Text: task.Reason}
+				suite.Failures++
+			case StatusSkipped:
+				testCase.Skipped = &junitMessage{Message: message}
+				suite.Skipped++
+			}
+			totalTime += task.Duration
+			suite.Cases = append(suite.Cases, testCase)
+		}
+		if len(store.Tasks) == 0 && store.Error != "" {
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      "workflow",
+				Classname: store.Store,
+				Error:     &junitMessage{Message: store.Error},
+			})
+			suite.Errors++
+		}
+		suite.Tests = len(suite.Cases)
+		suite.Time = fmt.Sprintf("%.3f", totalTime)
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
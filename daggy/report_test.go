@@ -0,0 +1,110 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testReport() *Report {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	prefetchEnd, shimcacheEnd := start.Add(2*time.Second), start.Add(time.Second)
+	items := 5
+	report := &Report{}
+	report.Add("example.forensicstore", Results{
+		"prefetch":  {Status: StatusSucceeded, Start: &start, End: &prefetchEnd, Stdout: "done", Items: &items},
+		"shimcache": {Status: StatusFailed, Reason: "parser error", Start: &start, End: &shimcacheEnd, ExitCode: 1},
+		"report":    {Status: StatusSkipped, Reason: "requirement shimcache failed"},
+	}, errors.New("shimcache: parser error"))
+	return report
+}
+
+func TestReport_WriteJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := testReport().Write(buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Stores []struct {
+			Store string
+			Error string
+			Tasks []struct {
+				Name     string
+				Status   Status
+				Duration float64
+				Items    *int
+				Stdout   string
+			}
+		}
+	}
+	if bytes.Contains(buf.Bytes(), []byte("0001-01-01")) {
+		t.Errorf("WriteJSON() contains zero times: %s", buf)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Stores) != 1 || len(got.Stores[0].Tasks) != 3 {
+		t.Fatalf("WriteJSON() = %s", buf)
+	}
+	prefetch := got.Stores[0].Tasks[0]
+	if prefetch.Name != "prefetch" || prefetch.Duration != 2 || prefetch.Items == nil || *prefetch.Items != 5 || prefetch.Stdout != "done" {
+		t.Errorf("WriteJSON() prefetch = %+v", prefetch)
+	}
+	if report := got.Stores[0].Tasks[1]; report.Items != nil {
+		t.Errorf("WriteJSON() report items = %d, want none", *report.Items)
+	}
+}
+
+func TestReport_WriteJUnit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := testReport().Write(buf, "junit"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Tests != 3 || got.Failures != 1 || got.Skipped != 1 {
+		t.Errorf("WriteJUnit() tests = %d, failures = %d, skipped = %d", got.Tests, got.Failures, got.Skipped)
+	}
+	if failure := got.Suites[0].Cases[2].Failure; failure == nil || failure.Message != "parser error" {
+		t.Errorf("WriteJUnit() failure = %v", failure)
+	}
+	wantProperties := []junitProperty{{Name: "attempts", Value: "0"}, {Name: "items", Value: "5"}}
+	if properties := got.Suites[0].Cases[0].Properties; !reflect.DeepEqual(properties, wantProperties) {
+		t.Errorf("WriteJUnit() properties = %v, want %v", properties, wantProperties)
+	}
+}
+
+func TestReport_WriteUnknown(t *testing.T) {
+	if err := testReport().Write(&bytes.Buffer{}, "csv"); err == nil {
+		t.Error("Write() expected error")
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"log"
+)
+
+// Resources assigns a task to a resource class. The number of tasks of a class
+// that run at the same time over all forensicstores can be limited with
+// max_concurrent on the task or in the resources section of the workflow.
+type Resources struct {
+	Class         string `yaml:"class"`
+	MaxConcurrent int    `yaml:"max_concurrent"`
+}
+
+// A ResourceClass defines the limit for all tasks of a resource class.
+type ResourceClass struct {
+	MaxConcurrent int `yaml:"max_concurrent"`
+}
+
+// resourceClass returns the resource class of a task. Tasks that set
+// max_concurrent without a class form a class of their own.
+func (workflow *Workflow) resourceClass(taskName string) string {
+	resources := workflow.Tasks[taskName].Resources
+	if resources.Class != "" {
+		return resources.Class
+	}
+	if resources.MaxConcurrent > 0 {
+		return taskName
+	}
+	return ""
+}
+
+// classLimits returns the smallest limit defined for every resource class.
+func (workflow *Workflow) classLimits() map[string]int {
+	limits := map[string]int{}
+	setLimit := func(class string, limit int) {
+		if current, ok := limits[class]; limit > 0 && (!ok || limit < current) {
+			limits[class] = limit
+		}
+	}
+	for class, resourceClass := range workflow.Resources {
+		setLimit(class, resourceClass.MaxConcurrent)
+	}
+	for name, task := range workflow.Tasks {
+		if class := workflow.resourceClass(name); class != "" {
+			setLimit(class, task.Resources.MaxConcurrent)
+		}
+	}
+	return limits
+}
+
+func (workflow *Workflow) setupLimits() {
+	workflow.slots = nil
+	if workflow.MaxParallel > 0 {
+		workflow.slots = make(chan struct{}, workflow.MaxParallel)
+	}
+	workflow.classSlots = map[string]chan struct{}{}
+	for class, limit := range workflow.classLimits() {
+		workflow.classSlots[class] = make(chan struct{}, limit)
+	}
+}
+
+// acquire blocks until the task may be started or ctx is canceled. The slot
+// of the resource class is taken first, so waiting tasks do not block tasks
+// of other classes.
+func (workflow *Workflow) acquire(ctx context.Context, taskName string) error {
+	if task := workflow.Tasks[taskName]; task.Type == typeWorkflow || task.ForEach != nil {
+		// the tasks of the nested workflow or the runs for the items take
+		// the slots
+		return ctx.Err()
+	}
+	class := workflow.resourceClass(taskName)
+	if err := takeSlot(ctx, workflow.classSlots[class], "Wait for resource class "+class); err != nil {
+		return err
+	}
+	if err := takeSlot(ctx, workflow.slots, "Wait for a free slot"); err != nil {
+		releaseSlot(workflow.classSlots[class])
+		return err
+	}
+	return nil
+}
+
+func (workflow *Workflow) release(taskName string) {
+	if task := workflow.Tasks[taskName]; task.Type == typeWorkflow || task.ForEach != nil {
+		return
+	}
+	releaseSlot(workflow.slots)
+	releaseSlot(workflow.classSlots[workflow.resourceClass(taskName)])
+}
+
+func takeSlot(ctx context.Context, slots chan struct{}, msg string) error {
+	if slots == nil {
+		return ctx.Err()
+	}
+	select {
+	case slots <- struct{}{}:
+		return nil
+	default:
+	}
+	log.Println(msg)
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func releaseSlot(slots chan struct{}) {
+	if slots != nil {
+		<-slots
+	}
+}
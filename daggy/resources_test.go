@@ -0,0 +1,98 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestWorkflow_RunResources(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggyresources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	// heavy tasks fail if another heavy task holds the lock directory
+	heavy := Task{Type: "bash", Command: "mkdir heavy.lock && sleep 0.2 && rmdir heavy.lock", Resources: Resources{Class: "heavy"}}
+	single := Task{Type: "bash", Command: "mkdir single.lock && sleep 0.2 && rmdir single.lock", Resources: Resources{MaxConcurrent: 1}}
+	workflow := Workflow{
+		Tasks: map[string]Task{
+			"heavy1": heavy, "heavy2": heavy, "heavy3": heavy,
+			"single": single,
+			"light1": {Type: "bash", Command: "true"},
+			"light2": {Type: "bash", Command: "true"},
+		},
+		Resources: map[string]ResourceClass{"heavy": {MaxConcurrent: 1}},
+	}
+	workflow.SetupGraph()
+
+	results, err := workflow.Run(context.Background(), storeDir, "", nil, nil)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for name, result := range results {
+		if result.Status != StatusSucceeded {
+			t.Errorf("Run() %s status = %v (%s)", name, result.Status, result.Reason)
+		}
+	}
+
+	// a task with max_concurrent forms its own class over all runs
+	done := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := workflow.Run(context.Background(), storeDir, "", nil, nil)
+			done <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	}
+}
+
+func TestWorkflow_classLimits(t *testing.T) {
+	workflow := Workflow{
+		Tasks: map[string]Task{
+			"a": {Resources: Resources{Class: "heavy", MaxConcurrent: 3}},
+			"b": {Resources: Resources{Class: "heavy"}},
+			"c": {Resources: Resources{MaxConcurrent: 2}},
+			"d": {Resources: Resources{Class: "unlimited"}},
+			"e": {},
+		},
+		Resources: map[string]ResourceClass{"heavy": {MaxConcurrent: 1}},
+	}
+	got := workflow.classLimits()
+	want := map[string]int{"heavy": 1, "c": 2}
+	if len(got) != len(want) {
+		t.Fatalf("classLimits() = %v, want %v", got, want)
+	}
+	for class, limit := range want {
+		if got[class] != limit {
+			t.Errorf("classLimits() = %v, want %v", got, want)
+		}
+	}
+}
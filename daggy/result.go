@@ -0,0 +1,65 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"time"
+)
+
+// Status describes the state of a task in a workflow run.
+type Status string
+
+// Possible task states.
+const (
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusSkipped   Status = "skipped"
+	StatusCached    Status = "cached"
+)
+
+// A TaskResult is the outcome of a task in a workflow run.
+type TaskResult struct {
+	Status   Status     `json:"status"`
+	Reason   string     `json:"reason,omitempty"`
+	Start    *time.Time `json:"start,omitempty"` // unset if the task was not started
+	End      *time.Time `json:"end,omitempty"`
+	Attempts int        `json:"attempts,omitempty"`
+	ExitCode int        `json:"exit_code"`
+	Stdout   string     `json:"stdout,omitempty"`
+	Stderr   string     `json:"stderr,omitempty"`
+	Outputs  Outputs    `json:"outputs,omitempty"`
+
+	// Items is the number of items the task added to the forensicstore. It
+	// is unset if the number is not known, e.g. because other tasks changed
+	// the store at the same time.
+	Items *int `json:"items,omitempty"`
+}
+
+// Results contains the TaskResult of every task of a workflow run.
+type Results map[string]*TaskResult
+
+// now returns the current time for the start and end of a task.
+func now() *time.Time {
+	t := time.Now().UTC()
+	return &t
+}
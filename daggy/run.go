@@ -0,0 +1,354 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform/dag"
+	"github.com/hashicorp/terraform/tfdiags"
+	"github.com/pkg/errors"
+)
+
+// A run holds the state of a single execution of a workflow on a
+// forensicstore, so a workflow can be run on multiple stores in parallel.
+type run struct {
+	workflow   *Workflow
+	workingDir string
+	pluginDir  string
+	plugins    map[string]Plugin
+	arguments  Arguments
+	vars       map[string]string
+	secrets    map[string]string // values of the secret vars
+	journal    *Journal
+	id         string // labels the containers of the run
+	prefix     string // journal name prefix of the tasks of a nested workflow
+	images     *pulledImages
+
+	mutex    sync.Mutex
+	results  Results
+	resumed  map[string]bool // tasks skipped because they succeeded before
+	blocking map[string]bool // tasks that cause their dependents to be skipped
+}
+
+func (r *run) walk(ctx context.Context) (Results, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := &dag.Walker{Callback: func(v dag.Vertex) tfdiags.Diagnostics {
+		return r.visit(runCtx, cancel, v.(string))
+	}}
+	w.Update(r.workflow.graph)
+	w.Wait()
+
+	// tasks without result were skipped by the walker as a requirement failed
+	for _, taskName := range r.workflow.taskNames() {
+		r.skipReason(taskName)
+	}
+
+	var diags tfdiags.Diagnostics
+	for _, taskName := range r.workflow.taskNames() {
+		task, result := r.workflow.Tasks[taskName], r.results[taskName]
+		if result.Status == StatusFailed && (task.OnError == "" || task.OnError == OnErrorFail) {
+			diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, taskName, result.Reason))
+		}
+	}
+	if ctx.Err() != nil {
+		diags = diags.Append(errors.Wrap(ctx.Err(), "workflow canceled"))
+	}
+	return r.results, diags.Err()
+}
+
+// visit is called by the walker for every task whose requirements succeeded.
+func (r *run) visit(ctx context.Context, cancel context.CancelFunc, taskName string) tfdiags.Diagnostics {
+	task := r.workflow.Tasks[taskName]
+	hash := inputHash(task, r.arguments, r.vars)
+
+	if ctx.Err() != nil {
+		r.setResult(taskName, &TaskResult{Status: StatusSkipped, Reason: "workflow canceled"}, true)
+		return taskDiagnostics(taskName, ctx.Err())
+	}
+
+	r.mutex.Lock()
+	resumable := r.workflow.Resume && r.journal.Succeeded(r.prefix+taskName, hash)
+	for _, requirement := range task.Requires {
+		resumable = resumable && r.resumed[requirement]
+	}
+	r.resumed[taskName] = resumable
+	r.mutex.Unlock()
+
+	if resumable {
+		log.Println("Skip", r.prefix+taskName, "(succeeded before)")
+		r.setResult(taskName, &TaskResult{Status: StatusSkipped, Reason: "succeeded before", Outputs: r.journal.outputs(r.prefix + taskName)}, false)
+		return nil
+	}
+
+	if ok, err := r.condition(task); err != nil {
+		r.record(taskName, hash, "", &TaskResult{Status: StatusFailed, Reason: err.Error(), ExitCode: -1}, task.OnError != OnErrorContinue)
+		return r.failed(cancel, taskName, err)
+	} else if !ok {
+		// dependents are skipped, but the workflow does not fail
+		log.Println("Skip", r.prefix+taskName, "(condition is false)")
+		r.record(taskName, hash, "", &TaskResult{Status: StatusSkipped, Reason: fmt.Sprintf("condition `%s` is false", task.When)}, true)
+		return taskDiagnostics(taskName, errors.New("condition is false"))
+	}
+
+	if err := r.workflow.acquire(ctx, taskName); err != nil {
+		r.setResult(taskName, &TaskResult{Status: StatusSkipped, Reason: "workflow canceled"}, true)
+		return taskDiagnostics(taskName, err)
+	}
+
+	// the cache key reads the store and pulls images, so it is computed
+	// within the limits of the task
+	cacheKey := ""
+	if !r.workflow.NoCache {
+		var cacheable bool
+		cacheKey, cacheable = r.cacheKey(ctx, task)
+		if cacheable && r.journal.cached(r.prefix+taskName, cacheKey) {
+			r.workflow.release(taskName)
+			log.Println("Skip", r.prefix+taskName, "(cached)")
+			r.record(taskName, hash, cacheKey, &TaskResult{Status: StatusCached, Reason: "inputs unchanged", Outputs: r.journal.outputs(r.prefix + taskName)}, false)
+			return nil
+		}
+	}
+
+	if err := r.journal.start(r.prefix+taskName, hash, cacheKey); err != nil {
+		log.Println("could not write journal:", err)
+	}
+	result := &TaskResult{Status: StatusSucceeded, Start: now()}
+	out := &output{task: r.prefix + taskName}
+	count := r.startItemCount(task)
+	err := r.attempt(withItemCount(ctx, count), taskName, result, out)
+	result.End = now()
+	result.Items = count.finish()
+	result.ExitCode = exitCode(err)
+	result.Stdout, result.Stderr = out.stdout.String(), out.stderr.String()
+	if err != nil {
+		result.Status = StatusFailed
+		result.Reason = err.Error()
+	} else {
+		result.Outputs = out.outputs
+	}
+	r.setResult(taskName, result, err != nil && task.OnError != OnErrorContinue)
+	if err := r.journal.finish(r.prefix+taskName, result); err != nil {
+		log.Println("could not write journal:", err)
+	}
+
+	if err == nil {
+		return nil
+	}
+	return r.failed(cancel, taskName, err)
+}
+
+// startItemCount starts to count the items added by a task. Tasks that only
+// wait for other tasks add no items themselves and are not counted.
+func (r *run) startItemCount(task Task) *itemCount {
+	if task.ForEach != nil || task.Type == typeGroup || task.Type == typeWorkflow {
+		return nil
+	}
+	return startItemCount(r.workingDir)
+}
+
+// record sets the result of a task that was not started and writes it to the
+// journal.
+func (r *run) record(taskName, hash, cacheKey string, result *TaskResult, block bool) {
+	r.setResult(taskName, result, block)
+	if err := r.journal.start(r.prefix+taskName, hash, cacheKey); err != nil {
+		log.Println("could not write journal:", err)
+	}
+	if err := r.journal.finish(r.prefix+taskName, result); err != nil {
+		log.Println("could not write journal:", err)
+	}
+}
+
+// failed handles a failed task according to its on_error policy.
+func (r *run) failed(cancel context.CancelFunc, taskName string, err error) tfdiags.Diagnostics {
+	switch r.workflow.Tasks[taskName].OnError {
+	case OnErrorContinue:
+		log.Println("Continue after failed task", taskName)
+		return nil
+	case OnErrorSkipDependents:
+		return taskDiagnostics(taskName, err)
+	default:
+		if r.workflow.FailFast {
+			log.Println("Cancel workflow after failed task", taskName)
+			cancel()
+		}
+		return taskDiagnostics(taskName, err)
+	}
+}
+
+// condition evaluates the when condition of a task.
+func (r *run) condition(task Task) (bool, error) {
+	if task.When == "" {
+		return true, nil
+	}
+	w, err := parseWhen(task.When)
+	if err != nil {
+		return false, err
+	}
+	value, err := w.eval(conditionEnv{r})
+	if err != nil {
+		return false, fmt.Errorf("when: %s", err)
+	}
+	return truthy(value), nil
+}
+
+// conditionEnv resolves the references of when conditions. In addition to
+// templates, conditions can reference the arguments of the workflow.
+type conditionEnv struct {
+	*run
+}
+
+func (env conditionEnv) value(reference string) (string, error) {
+	if strings.HasPrefix(reference, "with.") {
+		return env.arguments.Get(strings.TrimPrefix(reference, "with.")), nil
+	}
+	return env.run.value(reference)
+}
+
+func (env conditionEnv) count(itemType string, filter Filter) (int, error) {
+	return countItemsMatching(env.workingDir, itemType, filter)
+}
+
+// attempt runs a task until it succeeds or its retries are used up. The slots
+// of the task must be acquired before and are released while waiting for the
+// next attempt. Only the output of the last attempt is kept.
+func (r *run) attempt(ctx context.Context, taskName string, result *TaskResult, out *output) error {
+	task := r.workflow.Tasks[taskName]
+	for {
+		result.Attempts++
+		out.stdout.reset()
+		out.stderr.reset()
+		out.pluginOutputs, out.outputs = nil, nil
+		err := r.runTask(ctx, taskName, out)
+		r.workflow.release(taskName)
+		if err == nil {
+			if result.Attempts > 1 {
+				log.Printf("Task %s succeeded after %d attempts", taskName, result.Attempts)
+			}
+			return nil
+		}
+		// the runs of a for_each task are retried on their own
+		if result.Attempts > task.Retries || task.ForEach != nil || ctx.Err() != nil {
+			return err
+		}
+
+		delay := task.retryDelay(result.Attempts)
+		log.Printf("Task %s failed (attempt %d of %d), retry in %s: %s", taskName, result.Attempts, task.Retries+1, delay, strings.SplitN(strings.TrimSpace(err.Error()), "\n", 2)[0])
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		if r.workflow.acquire(ctx, taskName) != nil {
+			return err
+		}
+	}
+}
+
+// setResult stores the result of a task with all secrets removed, before it
+// is written to the journal or reports.
+func (r *run) setResult(taskName string, result *TaskResult, block bool) {
+	result.Stdout, result.Stderr, result.Reason = redact(result.Stdout), redact(result.Stderr), redact(result.Reason)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.results[taskName] = result
+	r.blocking[taskName] = block
+}
+
+func taskDiagnostics(taskName string, err error) tfdiags.Diagnostics {
+	return tfdiags.Diagnostics{tfdiags.Sourceless(tfdiags.Error, fmt.Sprint(taskName), err.Error())}
+}
+
+// skipReason adds a result for a task that was never started, because one of
+// its requirements failed or was skipped.
+func (r *run) skipReason(taskName string) *TaskResult {
+	if result, ok := r.results[taskName]; ok {
+		return result
+	}
+	result := &TaskResult{Status: StatusSkipped, Reason: "requirement failed"}
+	for _, requirement := range r.workflow.Tasks[taskName].Requires {
+		requirementResult := r.skipReason(requirement)
+		if r.blocking[requirement] {
+			if requirementResult.Status == StatusFailed {
+				result.Reason = fmt.Sprintf("requirement %s failed", requirement)
+			} else {
+				result.Reason = fmt.Sprintf("requirement %s was skipped", requirement)
+			}
+			break
+		}
+	}
+	r.results[taskName] = result
+	r.blocking[taskName] = true
+	return result
+}
+
+func (r *run) runTask(ctx context.Context, taskName string, out *output) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if task := r.workflow.Tasks[taskName]; task.ForEach != nil {
+		// the item expressions are replaced for every item
+		return r.runForEach(ctx, taskName, task)
+	}
+	task, err := r.resolve(r.workflow.Tasks[taskName])
+	if err != nil {
+		return err
+	}
+	if task.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, task.Timeout)
+		defer cancel()
+	}
+
+	log.Println("Start", r.prefix+taskName)
+	defer log.Println("End", r.prefix+taskName)
+	switch task.Type {
+	case "bash":
+		err = bash(ctx, task.Command, task.Arguments, task.Filter, secretEnvList(r.taskSecrets(task)), r, out)
+	case "docker":
+		err = docker(ctx, task.Image, task.Command, task.Arguments, task.Filter, r.containerOptions(task), r, out)
+	case "dockerfile":
+		err = dockerfile(ctx, task.Dockerfile, task.Arguments, task.Filter, r.containerOptions(task), r, out)
+	case "plugin":
+		err = plugin(ctx, task.Command, task.Arguments, task.Filter, r.taskSecrets(task), r, out)
+	case typeWorkflow:
+		err = r.runWorkflow(ctx, taskName, task)
+	case typeGroup:
+	default:
+		err = errors.New("unknown type")
+	}
+	if err != nil {
+		return err
+	}
+	out.outputs, err = collectOutputs(task, r.workingDir, out)
+	return err
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// redacted replaces secret values in logs and results.
+const redacted = "***"
+
+// secretValues contains the values of all secret vars bound so far.
+var secretValues = struct {
+	sync.RWMutex
+	values map[string]bool
+}{values: map[string]bool{}}
+
+// addSecret registers a value to be redacted from logs and results.
+func addSecret(value string) {
+	if value == "" {
+		return
+	}
+	secretValues.Lock()
+	defer secretValues.Unlock()
+	secretValues.values[value] = true
+}
+
+// redact replaces all secret values in s.
+func redact(s string) string {
+	secretValues.RLock()
+	defer secretValues.RUnlock()
+	if len(secretValues.values) == 0 {
+		return s
+	}
+	var values []string
+	for value := range secretValues.values {
+		values = append(values, value)
+	}
+	// replace longer values first, as they might contain shorter ones
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, value := range values {
+		s = strings.ReplaceAll(s, value, redacted)
+	}
+	return s
+}
+
+// redactWriter removes secret values from everything written to the log.
+// The log package writes every message with a single call.
+type redactWriter struct {
+	writer io.Writer
+}
+
+func (w *redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.writer, redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// secretEnv returns the name of the environment variable a secret var is
+// passed in, e.g. VT_API_KEY for vt-api-key.
+func secretEnv(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return '_'
+		}
+		return unicode.ToUpper(r)
+	}, name)
+}
+
+// taskSecrets returns the values of the secret vars passed to a task.
+func (r *run) taskSecrets(task Task) map[string]string {
+	if len(task.Secrets) == 0 {
+		return nil
+	}
+	secrets := map[string]string{}
+	for _, name := range task.Secrets {
+		secrets[name] = r.secrets[name]
+	}
+	return secrets
+}
+
+// secretEnvList returns the secrets as environment variables.
+func secretEnvList(secrets map[string]string) []string {
+	var env []string
+	for _, name := range sortedKeys(secrets) {
+		env = append(env, secretEnv(name)+"="+secrets[name])
+	}
+	return env
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_secretEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"token", "TOKEN"},
+		{"vt-api-key", "VT_API_KEY"},
+		{"s3.key", "S3_KEY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := secretEnv(tt.name); got != tt.want {
+				t.Errorf("secretEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkflow_RunSecrets(t *testing.T) {
+	const secret = "s3cr3t-value"
+	if err := os.Setenv("DAGGY_TEST_TOKEN", secret); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("DAGGY_TEST_TOKEN")
+
+	storeDir, err := ioutil.TempDir("", "daggysecrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	workflow := Workflow{
+		Vars: map[string]Var{"token": {Secret: true, Default: "${{ env.DAGGY_TEST_TOKEN }}"}},
+		Tasks: map[string]Task{
+			"a": {Type: "bash", Command: `case "$*" in *` + secret + `*) exit 2;; esac; printf %s "$TOKEN" > token.txt; echo "token $TOKEN"; echo "$TOKEN" >&2; exit 1`, Secrets: []string{"token"}},
+			"b": {Type: "bash", Command: `test -z "$TOKEN"`},
+		},
+	}
+	workflow.SetupGraph()
+	results, _ := workflow.Run(context.Background(), storeDir, "", nil, nil)
+
+	b, err := ioutil.ReadFile(filepath.Join(storeDir, "token.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != secret {
+		t.Errorf("token.txt = %q, want %q", b, secret)
+	}
+	if result := results["a"]; result.ExitCode != 1 || result.Stdout != "token ***\n" || result.Stderr != "***\n" {
+		t.Errorf("result a = %+v, want exit code 1 and redacted output", result)
+	}
+	if result := results["b"]; result.Status != StatusSucceeded {
+		t.Errorf("result b = %+v, want task without secrets to not get them", result)
+	}
+
+	journal, err := ioutil.ReadFile(filepath.Join(storeDir, JournalFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"journal": string(journal), "log": logs.String()} {
+		if strings.Contains(content, secret) {
+			t.Errorf("%s contains secret: %s", name, content)
+		}
+	}
+}
+
+func Test_dockerRunCommandSecrets(t *testing.T) {
+	r := &run{workflow: &Workflow{}, workingDir: "/store", pluginDir: "/plugins"}
+	opts := containerOptions{secrets: map[string]string{"token": "s3cr3t-value"}}
+	got := shellJoin(dockerRunCommand("alpine", "true", nil, nil, opts, r))
+	if !strings.Contains(got, "-e TOKEN ") || strings.Contains(got, "s3cr3t-value") {
+		t.Errorf("dockerRunCommand() = %s, want secret passed by name only", got)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3" // Import sqlite3 driver
+)
+
+// openItemDB opens the item database of the forensicstore in storeDir.
+func openItemDB(storeDir string) (*sql.DB, error) {
+	dbPath := filepath.Join(storeDir, "item.db")
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, err
+	}
+	return sql.Open("sqlite3", dbPath)
+}
+
+// itemTables returns the names of all tables that contain items.
+func itemTables(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type='table'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(name, "sqlite") || strings.HasPrefix(name, "_") {
+			continue
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// maxRowIDs returns the highest rowid of every item table. Items are added
+// with increasing rowids, so the difference is the number of added items
+// without counting all rows.
+func maxRowIDs(storeDir string) (map[string]int64, error) {
+	db, err := openItemDB(storeDir)
+	if os.IsNotExist(err) {
+		return map[string]int64{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tables, err := itemTables(db)
+	if err != nil {
+		return nil, err
+	}
+	rowIDs := map[string]int64{}
+	for _, table := range tables {
+		var rowID sql.NullInt64
+		if err := db.QueryRow(fmt.Sprintf(`SELECT max(rowid) FROM "%s"`, table)).Scan(&rowID); err != nil { // #nosec
+			return nil, err
+		}
+		rowIDs[table] = rowID.Int64
+	}
+	return rowIDs, nil
+}
+
+// An itemCount counts the items a task adds to the forensicstore. Built-in
+// plugins report their inserts with CountItems. For other tasks the count is
+// taken from the store, which is only exact if no other task changed the store
+// at the same time.
+type itemCount struct {
+	storeDir string
+	before   map[string]int64
+
+	mutex    sync.Mutex
+	overlap  bool // other tasks ran on the store at the same time
+	counted  bool // inserts were reported with CountItems
+	inserted int
+}
+
+// runningCounts holds the item counts of the running tasks of every store.
+var runningCounts = struct {
+	sync.Mutex
+	stores map[string][]*itemCount
+}{stores: map[string][]*itemCount{}}
+
+func startItemCount(storeDir string) *itemCount {
+	c := &itemCount{storeDir: storeDir}
+
+	runningCounts.Lock()
+	for _, other := range runningCounts.stores[storeDir] {
+		other.setOverlap()
+		c.overlap = true
+	}
+	runningCounts.stores[storeDir] = append(runningCounts.stores[storeDir], c)
+	runningCounts.Unlock()
+
+	var err error
+	if c.before, err = maxRowIDs(storeDir); err != nil {
+		log.Println("could not count items:", err)
+	}
+	return c
+}
+
+func (c *itemCount) setOverlap() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.overlap = true
+}
+
+// finish returns the number of added items or nil if it is not known.
+func (c *itemCount) finish() *int {
+	if c == nil {
+		return nil
+	}
+	runningCounts.Lock()
+	running := runningCounts.stores[c.storeDir]
+	for i, other := range running {
+		if other == c {
+			runningCounts.stores[c.storeDir] = append(running[:i:i], running[i+1:]...)
+			break
+		}
+	}
+	if len(runningCounts.stores[c.storeDir]) == 0 {
+		delete(runningCounts.stores, c.storeDir)
+	}
+	runningCounts.Unlock()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.counted {
+		return &c.inserted
+	}
+	if c.overlap || c.before == nil {
+		return nil
+	}
+	after, err := maxRowIDs(c.storeDir)
+	if err != nil {
+		log.Println("could not count items:", err)
+		return nil
+	}
+	items := 0
+	for table, rowID := range after {
+		if rowID > c.before[table] {
+			items += int(rowID - c.before[table])
+		}
+	}
+	return &items
+}
+
+type itemCountKey struct{}
+
+func withItemCount(ctx context.Context, c *itemCount) context.Context {
+	if c == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, itemCountKey{}, c)
+}
+
+// CountItems adds n items to the number of items the task of ctx added to the
+// forensicstore. Built-in plugins report their inserts, so the number is exact
+// even if other tasks run at the same time.
+func CountItems(ctx context.Context, n int) {
+	c, ok := ctx.Value(itemCountKey{}).(*itemCount)
+	if !ok {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counted = true
+	c.inserted += n
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestWorkflow_RunItems(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggyitems")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	workflow := Workflow{Tasks: map[string]Task{
+		"insert": {Type: "plugin", Command: "insert"},
+		"list":   {Type: "bash", Command: "ls", Requires: []string{"insert"}},
+	}}
+	workflow.SetupGraph()
+
+	plugins := map[string]Plugin{"insert": &insertPlugin{names: []string{"a", "b"}}}
+	results, err := workflow.Run(context.Background(), storeDir, "", plugins, Arguments{})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for name, want := range map[string]int{"insert": 2, "list": 0} {
+		if items := results[name].Items; items == nil || *items != want {
+			t.Errorf("task %s: items = %v, want %d", name, items, want)
+		}
+	}
+}
+
+func Test_itemCountOverlap(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggyitems")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	first := startItemCount(storeDir)
+	second := startItemCount(storeDir)
+	CountItems(withItemCount(context.Background(), second), 3)
+	if items := first.finish(); items != nil {
+		t.Errorf("overlapping count = %d, want none", *items)
+	}
+	if items := second.finish(); items == nil || *items != 3 {
+		t.Errorf("reported count = %v, want 3", items)
+	}
+	if _, ok := runningCounts.stores[storeDir]; ok {
+		t.Error("finished counts are still running")
+	}
+}
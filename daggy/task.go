@@ -22,62 +22,82 @@
 package daggy
 
 import (
-	"fmt"
-	"strings"
-
-	"github.com/forensicanalysis/forensicstore/gostore"
+	"sort"
+	"time"
 )
 
 // A Task is a single element in a workflow.yml file.
 type Task struct {
-	Type       string    `yaml:"type"`
-	Requires   []string  `yaml:"requires"`
-	Script     string    `yaml:"script"`     // bash
-	Image      string    `yaml:"image"`      // docker
-	Dockerfile string    `yaml:"dockerfile"` // dockerfile
-	Command    string    `yaml:"command"`    // shared
-	Arguments  Arguments `yaml:"with"`
-	Filter     Filter    `yaml:"filter"`
+	Type          string              `yaml:"type"`
+	Requires      []string            `yaml:"requires"`
+	When          string              `yaml:"when"`
+	Script        string              `yaml:"script"`     // bash
+	Image         string              `yaml:"image"`      // docker
+	Dockerfile    string              `yaml:"dockerfile"` // dockerfile
+	Workflow      string              `yaml:"workflow"`   // workflow
+	Command       string              `yaml:"command"`    // shared
+	Arguments     Arguments           `yaml:"with"`
+	Filter        Filter              `yaml:"filter"`
+	Inputs        []string            `yaml:"inputs"`
+	Secrets       []string            `yaml:"secrets"`
+	Timeout       time.Duration       `yaml:"timeout"`
+	OnError       string              `yaml:"on_error"`
+	Resources     Resources           `yaml:"resources"`
+	Retries       int                 `yaml:"retries"`
+	RetryDelay    time.Duration       `yaml:"retry_delay"`
+	Outputs       map[string]Output   `yaml:"outputs"`
+	Matrix        map[string][]string `yaml:"matrix"`
+	ForEach       *ForEach            `yaml:"for_each"`
+	KeepContainer bool                `yaml:"keep_container"` // docker, dockerfile
+	Container     `yaml:",inline"`    // docker, dockerfile
+	workflow      *Workflow
 }
 
-// A Filter is a list of mappings that should be used for a Task.
-type Filter []map[string]string
+// Policies for failing tasks. On fail, the workflow fails and all dependent
+// tasks are skipped. On continue, dependent tasks are run nevertheless. On
+// skip-dependents, dependent tasks are skipped but the workflow does not fail.
+const (
+	OnErrorFail           = "fail"
+	OnErrorContinue       = "continue"
+	OnErrorSkipDependents = "skip-dependents"
+)
 
-func (f Filter) toCommandline() []string {
-	var cmd []string
-	for _, conditions := range f {
-		filterStr := ""
-		for key, value := range conditions {
-			if filterStr != "" {
-				filterStr += ","
-			}
-			filterStr += key + "=" + value
-		}
-		cmd = append(cmd, "--filter", filterStr)
-	}
-	return cmd
-}
+const (
+	defaultRetryDelay = time.Second
+	maxRetryDelay     = 10 * time.Minute
+)
 
-// Match tests if an item matches the filter.
-func (f Filter) Match(item gostore.Item) bool {
-	if f == nil {
-		return true
+// retryDelay returns the time to wait after the given failed attempt. The
+// delay doubles with every attempt.
+func (t Task) retryDelay(attempt int) time.Duration {
+	delay := t.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
 	}
-	for _, condition := range f {
-		if f.matchCondition(condition, item) {
-			return true
-		}
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
 	}
-	return false
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
 }
 
-func (f Filter) matchCondition(condition map[string]string, item gostore.Item) bool {
-	for attribute, value := range condition {
-		if !strings.Contains(fmt.Sprint(item[attribute]), value) {
-			return false
-		}
+// field returns the value of a string field by its yaml name.
+func (t Task) field(name string) string {
+	switch name {
+	case "script":
+		return t.Script
+	case "image":
+		return t.Image
+	case "dockerfile":
+		return t.Dockerfile
+	case "command":
+		return t.Command
+	case "workflow":
+		return t.Workflow
 	}
-	return true
+	return ""
 }
 
 // Arguments is the input into the plugins.
@@ -92,8 +112,17 @@ func (a Arguments) Get(name string) string {
 }
 
 func (a Arguments) toCommandline() (cmd []string) {
-	for name, value := range a {
-		cmd = append(cmd, "--"+name, value)
+	for _, name := range sortedKeys(a) {
+		cmd = append(cmd, "--"+name, a[name])
 	}
 	return cmd
 }
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -22,74 +22,69 @@
 package daggy
 
 import (
-	"reflect"
+	"context"
+	"io/ioutil"
+	"os"
 	"testing"
-
-	"github.com/forensicanalysis/forensicstore/gostore"
+	"time"
 )
 
-func TestArguments_Get(t *testing.T) {
-	type args struct {
-		data  Arguments
-		field string
-	}
+func TestTask_retryDelay(t *testing.T) {
 	tests := []struct {
-		name string
-		args args
-		want string
+		name       string
+		retryDelay time.Duration
+		attempt    int
+		want       time.Duration
 	}{
-		{"good path", args{Arguments{"foo": "bar"}, "foo"}, "bar"},
-		{"not existing", args{Arguments{"baz": "bar"}, "foo"}, ""},
+		{"default", 0, 1, time.Second},
+		{"first", 3 * time.Second, 1, 3 * time.Second},
+		{"backoff", 3 * time.Second, 3, 12 * time.Second},
+		{"max", time.Minute, 20, maxRetryDelay},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := tt.args.data.Get(tt.args.field)
-			if got != tt.want {
-				t.Errorf("getStringField() got = %v, want %v", got, tt.want)
+			task := Task{RetryDelay: tt.retryDelay}
+			if got := task.retryDelay(tt.attempt); got != tt.want {
+				t.Errorf("retryDelay() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
-func TestFilter_toCommandline(t *testing.T) {
+func TestWorkflow_RunRetries(t *testing.T) {
+	// flaky fails on the first two runs
+	flaky := "n=$(cat attempts 2>/dev/null || echo 0); n=$((n+1)); echo $n > attempts; [ $n -ge 3 ]"
+
 	tests := []struct {
-		name string
-		f    Filter
-		want []string
+		name         string
+		retries      int
+		wantStatus   Status
+		wantAttempts int
 	}{
-		// {"simple", []map[string]string{{"file": "exe", "path": "Windows"}, {"file": "dll", "path": "Downloads"}}, []string{"--filter", "file=exe,path=Windows", "--filter", "file=dll,path=Download"}},
+		{"no retries", 0, StatusFailed, 1},
+		{"too few retries", 1, StatusFailed, 2},
+		{"enough retries", 2, StatusSucceeded, 3},
+		{"more retries", 5, StatusSucceeded, 3},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.f.toCommandline(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("toCommandline() = %v, want %v", got, tt.want)
+			storeDir, err := ioutil.TempDir("", "daggyretries")
+			if err != nil {
+				t.Fatal(err)
 			}
-		})
-	}
-}
+			defer os.RemoveAll(storeDir)
 
-func TestFilter_Match(t *testing.T) {
-	type args struct {
-		item gostore.Item
-	}
-	tests := []struct {
-		name string
-		f    Filter
-		args args
-		want bool
-	}{
-		{"simple match", Filter{{"name": "foo"}}, args{gostore.Item{"name": "foo"}}, true},
-		{"no match", Filter{{"name": "foo"}}, args{gostore.Item{"name": "bar"}}, false},
-		{"nil filter", nil, args{gostore.Item{"name": "foo"}}, true},
-		{"contains match", Filter{{"name": "foo"}}, args{gostore.Item{"name": "xfool"}}, true},
-		{"simple match", Filter{{"name": "foo"}}, args{gostore.Item{"name": "foo", "bar": "baz"}}, true},
-		{"multi match", Filter{{"name": "foo", "bar": "baz"}}, args{gostore.Item{"name": "foo", "bar": "baz"}}, true},
-		{"any match", Filter{{"x": "y"}, {"name": "foo", "bar": "baz"}}, args{gostore.Item{"name": "foo", "bar": "baz"}}, true},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.f.Match(tt.args.item); got != tt.want {
-				t.Errorf("Match() = %v, want %v", got, tt.want)
+			workflow := Workflow{Tasks: map[string]Task{
+				"flaky": {Type: "bash", Command: flaky, Retries: tt.retries, RetryDelay: 10 * time.Millisecond},
+			}}
+			workflow.SetupGraph()
+
+			results, _ := workflow.Run(context.Background(), storeDir, "", nil, nil)
+			if results["flaky"].Status != tt.wantStatus {
+				t.Errorf("Run() status = %v, want %v", results["flaky"].Status, tt.wantStatus)
+			}
+			if results["flaky"].Attempts != tt.wantAttempts {
+				t.Errorf("Run() attempts = %v, want %v", results["flaky"].Attempts, tt.wantAttempts)
 			}
 		})
 	}
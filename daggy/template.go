@@ -0,0 +1,139 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// templatePattern matches expressions like ${{ tasks.run_keys.outputs.count }},
+// ${{ vars.case }} or ${{ env.HOME }}.
+var templatePattern = regexp.MustCompile(`\$\{\{\s*(.*?)\s*\}\}`)
+
+// expand replaces all expressions in s by the value returned by resolve.
+func expand(s string, resolve func(expression string) (string, error)) (string, error) {
+	var err error
+	expanded := templatePattern.ReplaceAllStringFunc(s, func(match string) string {
+		if err != nil {
+			return ""
+		}
+		value, resolveErr := resolve(templatePattern.FindStringSubmatch(match)[1])
+		if resolveErr != nil {
+			err = resolveErr
+		}
+		return value
+	})
+	return expanded, err
+}
+
+// templateExpressions returns all expressions in s.
+func templateExpressions(s string) []string {
+	var expressions []string
+	for _, match := range templatePattern.FindAllStringSubmatch(s, -1) {
+		expressions = append(expressions, match[1])
+	}
+	return expressions
+}
+
+// outputReference parses an expression of the form tasks.<task>.outputs.<name>.
+func outputReference(expression string) (taskName, name string, err error) {
+	parts := strings.Split(expression, ".")
+	if len(parts) != 4 || parts[0] != "tasks" || parts[2] != "outputs" || parts[1] == "" || parts[3] == "" {
+		return "", "", fmt.Errorf("unknown expression `%s`", expression)
+	}
+	return parts[1], parts[3], nil
+}
+
+// resolve returns a copy of the task with all expressions in its command and
+// arguments replaced.
+func (r *run) resolve(task Task) (Task, error) {
+	return resolveTask(task, r.commandValue, r.value)
+}
+
+// resolveTask replaces the expressions in the command of a task with the
+// values returned by commandValue and in its arguments with the values
+// returned by value.
+func resolveTask(task Task, commandValue, value func(expression string) (string, error)) (Task, error) {
+	var err error
+	if task.Command, err = expand(task.Command, commandValue); err != nil {
+		return task, fmt.Errorf("command: %s", err)
+	}
+	if task.Arguments != nil {
+		arguments := Arguments{}
+		for _, name := range sortedKeys(task.Arguments) {
+			if arguments[name], err = expand(task.Arguments[name], value); err != nil {
+				return task, fmt.Errorf("with %s: %s", name, err)
+			}
+		}
+		task.Arguments = arguments
+	}
+	return task, nil
+}
+
+// value resolves an expression during a run.
+func (r *run) value(expression string) (string, error) {
+	if strings.HasPrefix(expression, "tasks.") {
+		return r.outputValue(expression)
+	}
+	return (&binding{vars: r.vars, secrets: r.secrets}).value(expression)
+}
+
+// commandValue resolves an expression in a command. Outputs of tasks are
+// quoted for sh, so they are always a single argument.
+func (r *run) commandValue(expression string) (string, error) {
+	value, err := r.value(expression)
+	if err != nil || !strings.HasPrefix(expression, "tasks.") {
+		return value, err
+	}
+	return shellJoin([]string{value}), nil
+}
+
+// planValue resolves vars and env expressions for a plan. Outputs of tasks and
+// items of for_each tasks are only known during the run and kept as
+// placeholders.
+func (r *run) planValue(expression string) (string, error) {
+	if strings.HasPrefix(expression, "tasks.") || strings.HasPrefix(expression, "item.") {
+		return "${{ " + expression + " }}", nil
+	}
+	return r.value(expression)
+}
+
+// outputValue returns the value of an output of a task that ran before.
+func (r *run) outputValue(expression string) (string, error) {
+	taskName, name, err := outputReference(expression)
+	if err != nil {
+		return "", err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	result, ok := r.results[taskName]
+	if !ok {
+		return "", fmt.Errorf("task %s has not run", taskName)
+	}
+	value, ok := result.Outputs[name]
+	if !ok {
+		return "", fmt.Errorf("task %s has no output %s", taskName, name)
+	}
+	return value, nil
+}
@@ -0,0 +1,464 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/dag"
+)
+
+// requiredFields lists the fields each task type needs to be executable.
+var requiredFields = map[string][]string{
+	"bash":       {"command"},
+	"docker":     {"image"},
+	"dockerfile": {"dockerfile"},
+	"plugin":     {"command"},
+	typeWorkflow: {"workflow"},
+}
+
+// A ValidationError describes a single problem in a workflow definition.
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Task    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	var location []string
+	if e.File != "" {
+		location = append(location, e.File)
+	}
+	if e.Line > 0 {
+		location = append(location, strconv.Itoa(e.Line), strconv.Itoa(e.Column))
+	}
+	msg := e.Message
+	if e.Task != "" {
+		msg = fmt.Sprintf("task %s: %s", e.Task, msg)
+	}
+	if len(location) > 0 {
+		return strings.Join(location, ":") + ": " + msg
+	}
+	return msg
+}
+
+// ValidationErrors collects all problems found in a workflow definition.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Validate checks the workflow for unknown task types, missing fields, dangling
+// or cyclic requirements and plugins that can neither be found in plugins nor
+// as a script in pluginDir. All problems are returned as ValidationErrors.
+func (workflow *Workflow) Validate(plugins map[string]Plugin, pluginDir string) error {
+	errs := workflow.validateTasks()
+	errs = append(errs, workflow.validatePlugins(plugins, pluginDir)...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (workflow *Workflow) validateTasks() ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range workflow.taskNames() {
+		task := workflow.Tasks[name]
+
+		if strings.Contains(name, "/") {
+			errs = append(errs, workflow.validationError(name, "task names must not contain /", "tasks", name))
+		}
+
+		fields, ok := requiredFields[task.Type]
+		switch {
+		case task.Type == "":
+			errs = append(errs, workflow.validationError(name, "missing type", "tasks", name))
+		case !ok:
+			errs = append(errs, workflow.validationError(name, fmt.Sprintf("unknown type `%s`", task.Type), "tasks", name, "type"))
+		}
+		for _, field := range fields {
+			if task.field(field) == "" {
+				errs = append(errs, workflow.validationError(name, fmt.Sprintf("missing %s for type %s", field, task.Type), "tasks", name))
+			}
+		}
+
+		switch task.OnError {
+		case "", OnErrorFail, OnErrorContinue, OnErrorSkipDependents:
+		default:
+			errs = append(errs, workflow.validationError(name, fmt.Sprintf("unknown on_error policy `%s`", task.OnError), "tasks", name, "on_error"))
+		}
+
+		for i, conditions := range task.Filter {
+			for _, attribute := range sortedKeys(conditions) {
+				if _, err := parseCondition(attribute, conditions[attribute]); err != nil {
+					errs = append(errs, workflow.validationError(name, "filter: "+err.Error(), "tasks", name, "filter", strconv.Itoa(i), attribute))
+				}
+			}
+		}
+
+		if task.Retries < 0 {
+			errs = append(errs, workflow.validationError(name, "retries must not be negative", "tasks", name, "retries"))
+		}
+		if task.RetryDelay < 0 {
+			errs = append(errs, workflow.validationError(name, "retry_delay must not be negative", "tasks", name, "retry_delay"))
+		}
+
+		if task.Resources.MaxConcurrent < 0 {
+			errs = append(errs, workflow.validationError(name, "max_concurrent must not be negative", "tasks", name, "resources", "max_concurrent"))
+		}
+
+		for i, requirement := range task.Requires {
+			if _, ok := workflow.Tasks[requirement]; !ok {
+				errs = append(errs, workflow.validationError(name, fmt.Sprintf("requires unknown task `%s`", requirement), "tasks", name, "requires", strconv.Itoa(i)))
+			}
+		}
+
+		for i, secret := range task.Secrets {
+			if !workflow.Vars[secret].Secret {
+				errs = append(errs, workflow.validationError(name, fmt.Sprintf("unknown secret var `%s`", secret), "tasks", name, "secrets", strconv.Itoa(i)))
+			}
+		}
+
+		errs = append(errs, workflow.validateOutputs(name)...)
+		errs = append(errs, workflow.validateTemplates(name)...)
+		errs = append(errs, workflow.validateWhen(name)...)
+		errs = append(errs, workflow.validateMatrix(name)...)
+		errs = append(errs, workflow.validateContainer(name, task.Container, "tasks", name)...)
+	}
+	for _, class := range workflow.resourceClassNames() {
+		if workflow.Resources[class].MaxConcurrent < 0 {
+			errs = append(errs, workflow.validationError("", fmt.Sprintf("resource class %s: max_concurrent must not be negative", class), "resources", class, "max_concurrent"))
+		}
+	}
+	errs = append(errs, workflow.validateContainer("", workflow.Container, "container")...)
+	if workflow.MaxParallel < 0 {
+		errs = append(errs, workflow.validationError("", "max_parallel must not be negative", "max_parallel"))
+	}
+	errs = append(errs, workflow.validateVars()...)
+	return append(errs, workflow.validateCycles()...)
+}
+
+func (workflow *Workflow) validateOutputs(name string) ValidationErrors {
+	var errs ValidationErrors
+	task := workflow.Tasks[name]
+	for _, output := range sortedOutputNames(task.Outputs) {
+		switch o := task.Outputs[output]; {
+		case o.From != "" && o.From != OutputFromStdout && o.From != OutputFromFile && o.From != OutputFromPlugin:
+			errs = append(errs, workflow.validationError(name, fmt.Sprintf("output %s: unknown source `%s`", output, o.From), "tasks", name, "outputs", output, "from"))
+		case o.From == OutputFromFile && o.Path == "":
+			errs = append(errs, workflow.validationError(name, fmt.Sprintf("output %s: missing path", output), "tasks", name, "outputs", output))
+		case o.From == OutputFromPlugin && task.Type != "plugin":
+			errs = append(errs, workflow.validationError(name, fmt.Sprintf("output %s: source plugin requires type plugin", output), "tasks", name, "outputs", output, "from"))
+		}
+	}
+	return errs
+}
+
+// validateTemplates checks that all expressions in the command and arguments
+// of a task reference declared outputs of tasks it requires.
+func (workflow *Workflow) validateTemplates(name string) ValidationErrors {
+	var errs ValidationErrors
+	check := func(value string, path ...string) {
+		for _, expression := range templateExpressions(value) {
+			if err := workflow.checkExpression(name, expression); err != nil {
+				errs = append(errs, workflow.validationError(name, err.Error(), append([]string{"tasks", name}, path...)...))
+			}
+		}
+	}
+	task := workflow.Tasks[name]
+	check(task.Command, "command")
+	for _, argument := range sortedKeys(task.Arguments) {
+		check(task.Arguments[argument], "with", argument)
+	}
+	return errs
+}
+
+// validateContainer checks the container settings of a task or the defaults of
+// the workflow.
+func (workflow *Workflow) validateContainer(task string, c Container, path ...string) ValidationErrors {
+	var errs ValidationErrors
+	at := func(field string) []string {
+		return append(append([]string{}, path...), field)
+	}
+	if memory, ok := parseNumber(c.Memory); c.Memory != "" && (!ok || memory <= 0) {
+		errs = append(errs, workflow.validationError(task, fmt.Sprintf("memory needs a positive size, got `%s`", c.Memory), at("memory")...))
+	}
+	if c.CPUs < 0 {
+		errs = append(errs, workflow.validationError(task, "cpus must not be negative", at("cpus")...))
+	}
+	switch c.Pull {
+	case "", PullAlways, PullIfNotPresent, PullNever:
+	default:
+		errs = append(errs, workflow.validationError(task, fmt.Sprintf("unknown pull policy `%s`, use always, if-not-present or never", c.Pull), at("pull")...))
+	}
+	switch c.Network {
+	case "", NetworkNone, NetworkBridge:
+	default:
+		errs = append(errs, workflow.validationError(task, fmt.Sprintf("unknown network `%s`, use none or bridge", c.Network), at("network")...))
+	}
+	return errs
+}
+
+func (workflow *Workflow) validateMatrix(name string) ValidationErrors {
+	var errs ValidationErrors
+	task := workflow.Tasks[name]
+	for _, key := range matrixKeys(task.Matrix) {
+		if len(task.Matrix[key]) == 0 {
+			errs = append(errs, workflow.validationError(name, fmt.Sprintf("matrix %s has no values", key), "tasks", name, "matrix", key))
+		}
+	}
+	if task.ForEach != nil {
+		if task.ForEach.Type == "" {
+			errs = append(errs, workflow.validationError(name, "for_each: missing type", "tasks", name, "for_each"))
+		}
+		if err := task.ForEach.Filter.Validate(); err != nil {
+			errs = append(errs, workflow.validationError(name, "for_each: filter: "+err.Error(), "tasks", name, "for_each", "filter"))
+		}
+	}
+	return errs
+}
+
+func (workflow *Workflow) validateWhen(name string) ValidationErrors {
+	task := workflow.Tasks[name]
+	if task.When == "" {
+		return nil
+	}
+	w, err := parseWhen(task.When)
+	if err != nil {
+		return ValidationErrors{workflow.validationError(name, err.Error(), "tasks", name, "when")}
+	}
+	var errs ValidationErrors
+	for _, reference := range references(w) {
+		if strings.HasPrefix(reference, "with.") {
+			continue
+		}
+		if err := workflow.checkExpression(name, reference); err != nil {
+			errs = append(errs, workflow.validationError(name, "when: "+err.Error(), "tasks", name, "when"))
+		}
+	}
+	return errs
+}
+
+func (workflow *Workflow) checkExpression(name, expression string) error {
+	switch {
+	case strings.HasPrefix(expression, "matrix."):
+		if _, ok := workflow.Tasks[name].Matrix[strings.TrimPrefix(expression, "matrix.")]; !ok {
+			return fmt.Errorf("unknown matrix value `%s`", strings.TrimPrefix(expression, "matrix."))
+		}
+		return nil
+	case strings.HasPrefix(expression, "item."):
+		if workflow.Tasks[name].ForEach == nil {
+			return fmt.Errorf("`%s` can only be used with for_each", expression)
+		}
+		return nil
+	case !strings.HasPrefix(expression, "tasks."):
+		return workflow.checkVarExpression(expression)
+	}
+	taskName, output, err := outputReference(expression)
+	if err != nil {
+		return err
+	}
+	task, ok := workflow.Tasks[taskName]
+	if !ok {
+		return fmt.Errorf("references unknown task `%s`", taskName)
+	}
+	if !workflow.requires(name, taskName, map[string]bool{}) {
+		return fmt.Errorf("references task `%s` which is not required", taskName)
+	}
+	if len(task.Matrix) > 0 || task.ForEach != nil {
+		return fmt.Errorf("outputs of task %s cannot be referenced as it runs multiple times", taskName)
+	}
+	if _, ok := task.Outputs[output]; !ok {
+		return fmt.Errorf("task %s has no output `%s`", taskName, output)
+	}
+	return nil
+}
+
+// checkVarExpression checks expressions that can be resolved before any task
+// runs, i.e. vars and environment variables.
+func (workflow *Workflow) checkVarExpression(expression string) error {
+	switch {
+	case strings.HasPrefix(expression, "vars."):
+		v, ok := workflow.Vars[strings.TrimPrefix(expression, "vars.")]
+		if !ok {
+			return fmt.Errorf("unknown var `%s`", strings.TrimPrefix(expression, "vars."))
+		}
+		if v.Secret {
+			return fmt.Errorf("secret var `%s` cannot be used in expressions, pass it with secrets", strings.TrimPrefix(expression, "vars."))
+		}
+		return nil
+	case strings.HasPrefix(expression, "env.") && len(expression) > len("env."):
+		return nil
+	}
+	return fmt.Errorf("unknown expression `%s`", expression)
+}
+
+func (workflow *Workflow) validateVars() ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range workflow.varNames() {
+		v := workflow.Vars[name]
+		if !varTypes[v.Type] {
+			errs = append(errs, workflow.validationError("", fmt.Sprintf("var %s: unknown type `%s`", name, v.Type), "vars", name, "type"))
+			continue
+		}
+		if v.Required && v.Default != "" {
+			errs = append(errs, workflow.validationError("", fmt.Sprintf("var %s: required vars cannot have a default", name), "vars", name, "default"))
+			continue
+		}
+		expressions := templateExpressions(v.Default)
+		for _, expression := range expressions {
+			if !strings.HasPrefix(expression, "env.") || workflow.checkVarExpression(expression) != nil {
+				errs = append(errs, workflow.validationError("", fmt.Sprintf("var %s: default can only use env, got `%s`", name, expression), "vars", name, "default"))
+			}
+		}
+		if len(expressions) == 0 && v.Default != "" {
+			if err := v.check(v.Default); err != nil {
+				errs = append(errs, workflow.validationError("", fmt.Sprintf("var %s: default %s", name, err), "vars", name, "default"))
+			}
+		}
+	}
+	for _, argument := range sortedKeys(workflow.Arguments) {
+		for _, expression := range templateExpressions(workflow.Arguments[argument]) {
+			if err := workflow.checkVarExpression(expression); err != nil {
+				errs = append(errs, workflow.validationError("", "with "+argument+": "+err.Error(), "with", argument))
+			}
+		}
+	}
+	return errs
+}
+
+// requires returns true if the task requires the other task directly or
+// indirectly.
+func (workflow *Workflow) requires(name, other string, visited map[string]bool) bool {
+	if visited[name] {
+		return false
+	}
+	visited[name] = true
+	for _, requirement := range workflow.Tasks[name].Requires {
+		if requirement == other || workflow.requires(requirement, other, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+func (workflow *Workflow) validateCycles() ValidationErrors {
+	graph := dag.AcyclicGraph{}
+	for name := range workflow.Tasks {
+		graph.Add(name)
+	}
+	for name, task := range workflow.Tasks {
+		for _, requirement := range task.Requires {
+			if _, ok := workflow.Tasks[requirement]; ok {
+				graph.Connect(dag.BasicEdge(requirement, name))
+			}
+		}
+	}
+
+	var errs ValidationErrors
+	for _, cycle := range graph.Cycles() {
+		var names []string
+		for _, vertex := range cycle {
+			names = append(names, dag.VertexName(vertex))
+		}
+		sort.Strings(names)
+		errs = append(errs, workflow.validationError(names[0], "requirement cycle: "+strings.Join(names, ", "), "tasks", names[0], "requires"))
+	}
+	for _, name := range workflow.taskNames() {
+		for _, requirement := range workflow.Tasks[name].Requires {
+			if requirement == name {
+				errs = append(errs, workflow.validationError(name, "task requires itself", "tasks", name, "requires"))
+			}
+		}
+	}
+	return errs
+}
+
+func (workflow *Workflow) validatePlugins(plugins map[string]Plugin, pluginDir string) ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range workflow.taskNames() {
+		task := workflow.Tasks[name]
+		if task.Type == typeWorkflow && task.workflow != nil {
+			errs = append(errs, task.workflow.validatePlugins(plugins, pluginDir)...)
+			continue
+		}
+		if task.Type != "plugin" || task.Command == "" {
+			continue
+		}
+		if _, ok := plugins[task.Command]; ok {
+			continue
+		}
+		if _, err := findScript(pluginDir, strings.Split(task.Command, " ")[0]); err != nil {
+			errs = append(errs, workflow.validationError(name, err.Error(), "tasks", name, "command"))
+		}
+	}
+	return errs
+}
+
+// validationError creates a ValidationError located at the most specific of
+// the given yaml path that is known from parsing.
+func (workflow *Workflow) validationError(task, message string, path ...string) *ValidationError {
+	err := &ValidationError{Task: task, Message: message}
+	if workflow.source == nil {
+		return err
+	}
+	src := workflow.source
+	if taskSource, ok := src.tasks[task]; ok {
+		src = taskSource
+	}
+	err.File = src.file
+	for i := len(path); i > 0; i-- {
+		if pos, ok := src.positions[joinPath(path[:i]...)]; ok {
+			err.Line, err.Column = pos.line, pos.column
+			break
+		}
+	}
+	return err
+}
+
+func (workflow *Workflow) resourceClassNames() []string {
+	var names []string
+	for name := range workflow.Resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (workflow *Workflow) taskNames() []string {
+	var names []string
+	for name := range workflow.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
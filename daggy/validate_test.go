@@ -0,0 +1,144 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeWorkflow(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "daggyvalidate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	workflowFile := filepath.Join(dir, "workflow.yml")
+	if err := ioutil.WriteFile(workflowFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return workflowFile
+}
+
+func TestParse_validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		workflow string
+		wantErrs []string
+	}{
+		{"valid", "tasks:\n  a:\n    type: bash\n    command: true\n", nil},
+		{"unknown type", "tasks:\n  a:\n    type: foo\n", []string{"3:5: task a: unknown type `foo`"}},
+		{"missing type", "tasks:\n  a:\n    command: true\n", []string{"2:3: task a: missing type"}},
+		{"missing command", "tasks:\n  a:\n    type: plugin\n", []string{"2:3: task a: missing command for type plugin"}},
+		{"missing image", "tasks:\n  a:\n    type: docker\n    command: true\n", []string{"2:3: task a: missing image for type docker"}},
+		{"dangling requires", "tasks:\n  a:\n    type: bash\n    command: true\n    requires: [b]\n", []string{"5:16: task a: requires unknown task `b`"}},
+		{"cycle", "tasks:\n  a:\n    type: bash\n    command: true\n    requires: [b]\n  b:\n    type: bash\n    command: true\n    requires: [a]\n", []string{"5:5: task a: requirement cycle: a, b"}},
+		{"self requirement", "tasks:\n  a:\n    type: bash\n    command: true\n    requires: [a]\n", []string{"5:5: task a: task requires itself"}},
+		{"invalid filter", "tasks:\n  a:\n    type: bash\n    command: true\n    filter:\n      - size: gt:big\n", []string{"6:9: task a: filter: gt for size needs a number, size or time, got `big`"}},
+		{"negative max_concurrent", "tasks:\n  a:\n    type: bash\n    command: true\n    resources:\n      max_concurrent: -1\n", []string{"6:7: task a: max_concurrent must not be negative"}},
+		{"unknown output source", "tasks:\n  a:\n    type: bash\n    command: true\n    outputs:\n      count:\n        from: env\n", []string{"7:9: task a: output count: unknown source `env`"}},
+		{"output reference", "tasks:\n  a:\n    type: bash\n    command: true\n    outputs:\n      count:\n  b:\n    type: bash\n    command: echo ${{ tasks.a.outputs.count }}\n    requires: [a]\n", nil},
+		{"unknown expression", "tasks:\n  a:\n    type: bash\n    command: echo ${{ foo }}\n", []string{"4:5: task a: unknown expression `foo`"}},
+		{"output not required", "tasks:\n  a:\n    type: bash\n    command: true\n    outputs:\n      count:\n  b:\n    type: bash\n    command: true\n    with:\n      n: ${{ tasks.a.outputs.count }}\n", []string{"11:7: task b: references task `a` which is not required"}},
+		{"undeclared output", "tasks:\n  a:\n    type: bash\n    command: true\n  b:\n    type: bash\n    command: echo ${{ tasks.a.outputs.count }}\n    requires: [a]\n", []string{"7:5: task b: task a has no output `count`"}},
+		{"vars", "vars:\n  case:\n    required: true\n  limit:\n    type: int\n    default: 10\nwith:\n  case: ${{ vars.case }}\ntasks:\n  a:\n    type: bash\n    command: echo ${{ vars.limit }} ${{ env.HOME }}\n", nil},
+		{"unknown var", "tasks:\n  a:\n    type: bash\n    command: echo ${{ vars.case }}\n", []string{"4:5: task a: unknown var `case`"}},
+		{"unknown var type", "vars:\n  case:\n    type: list\ntasks:\n  a:\n    type: bash\n    command: true\n", []string{"3:5: var case: unknown type `list`"}},
+		{"invalid var default", "vars:\n  limit:\n    type: int\n    default: many\ntasks:\n  a:\n    type: bash\n    command: true\n", []string{"4:5: var limit: default `many` is not of type int"}},
+		{"required var with default", "vars:\n  case:\n    required: true\n    default: 1\ntasks:\n  a:\n    type: bash\n    command: true\n", []string{"4:5: var case: required vars cannot have a default"}},
+		{"output in workflow with", "with:\n  a: ${{ tasks.a.outputs.b }}\ntasks:\n  a:\n    type: bash\n    command: true\n", []string{"2:3: with a: unknown expression `tasks.a.outputs.b`"}},
+		{"when", "tasks:\n  a:\n    type: bash\n    command: true\n    when: count(file, name=suffix:.pf) > 0 && with.os == 'windows'\n", nil},
+		{"invalid when", "tasks:\n  a:\n    type: bash\n    command: true\n    when: count(file\n", []string{"5:5: task a: when: missing ) in count"}},
+		{"unquoted when string", "tasks:\n  a:\n    type: bash\n    command: true\n    when: with.os == windows\n", []string{"5:5: task a: when: unknown expression `windows`"}},
+		{"matrix", "tasks:\n  a:\n    type: bash\n    command: echo ${{ matrix.os }}\n    matrix:\n      os: [windows, linux]\n  b:\n    type: bash\n    command: true\n    requires: [a]\n", nil},
+		{"empty matrix", "tasks:\n  a:\n    type: bash\n    command: true\n    matrix:\n      os: []\n", []string{"6:7: task a: matrix os has no values"}},
+		{"unknown matrix value", "tasks:\n  a:\n    type: bash\n    command: echo ${{ matrix.arch }}\n    matrix:\n      os: [windows]\n", []string{"4:5: task a: unknown matrix value `arch`"}},
+		{"item without for_each", "tasks:\n  a:\n    type: bash\n    command: echo ${{ item.name }}\n", []string{"4:5: task a: `item.name` can only be used with for_each"}},
+		{"for_each without type", "tasks:\n  a:\n    type: bash\n    command: echo ${{ item.name }}\n    for_each:\n      filter:\n        - name: suffix:.pf\n", []string{"5:5: task a: for_each: missing type"}},
+		{"container", "container:\n  network: none\n  memory: 2GiB\ntasks:\n  a:\n    type: docker\n    image: alpine\n    cpus: 1.5\n    read_only_store: true\n    env:\n      TZ: UTC\n", nil},
+		{"invalid container", "container:\n  network: host\ntasks:\n  a:\n    type: docker\n    image: alpine\n    memory: lots\n    cpus: -1\n    pull: sometimes\n", []string{"7:5: task a: memory needs a positive size, got `lots`", "8:5: task a: cpus must not be negative", "9:5: task a: unknown pull policy `sometimes`, use always, if-not-present or never", "2:3: unknown network `host`, use none or bridge"}},
+		{"matrix outputs", "tasks:\n  a:\n    type: bash\n    command: true\n    matrix:\n      os: [windows]\n    outputs:\n      count:\n  b:\n    type: bash\n    command: echo ${{ tasks.a.outputs.count }}\n    requires: [a]\n", []string{"11:5: task b: outputs of task a cannot be referenced as it runs multiple times"}},
+		{"secrets", "vars:\n  token:\n    secret: true\n  case:\ntasks:\n  a:\n    type: bash\n    command: echo ${{ vars.token }}\n    secrets: [token, case]\n", []string{"9:22: task a: unknown secret var `case`", "8:5: task a: secret var `token` cannot be used in expressions, pass it with secrets"}},
+		{"negative class limit", "resources:\n  heavy:\n    max_concurrent: -1\ntasks:\n  a:\n    type: bash\n    command: true\n", []string{"3:5: resource class heavy: max_concurrent must not be negative"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflowFile := writeWorkflow(t, tt.workflow)
+			defer os.RemoveAll(filepath.Dir(workflowFile))
+
+			_, err := Parse(workflowFile)
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Errorf("Parse() error = %v", err)
+				}
+				return
+			}
+			errs, ok := err.(ValidationErrors)
+			if !ok {
+				t.Fatalf("Parse() error = %v, want ValidationErrors", err)
+			}
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("Parse() errors = %v, want %v", errs, tt.wantErrs)
+			}
+			for i, want := range tt.wantErrs {
+				if got := errs[i].Error(); got != workflowFile+":"+want {
+					t.Errorf("Parse() error = %v, want %v", got, workflowFile+":"+want)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkflow_Validate(t *testing.T) {
+	pluginDir, err := ioutil.TempDir("", "daggyplugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pluginDir)
+	if err := ioutil.WriteFile(filepath.Join(pluginDir, "script"), []byte("#!/bin/sh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(pluginDir, "folder"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr bool
+	}{
+		{"builtin plugin", "example", false},
+		{"script", "script --foo bar", false},
+		{"missing", "missing", true},
+		{"directory", "folder", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow := &Workflow{Tasks: map[string]Task{"task": {Type: "plugin", Command: tt.command}}}
+			plugins := map[string]Plugin{"example": &ExamplePlugin{}}
+			if err := workflow.Validate(plugins, pluginDir); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
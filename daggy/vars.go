@@ -0,0 +1,168 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Var is a variable of a workflow that can be set on the command line and
+// used in templates as ${{ vars.<name> }}. Secret vars cannot be used in
+// templates, they are only passed to the tasks that list them in secrets.
+type Var struct {
+	Default  string `yaml:"default"`
+	Required bool   `yaml:"required"`
+	Type     string `yaml:"type"` // string, int, number or bool
+	Help     string `yaml:"help"`
+	Secret   bool   `yaml:"secret"`
+}
+
+// varTypes lists the types a Var can have.
+var varTypes = map[string]bool{"": true, "string": true, "int": true, "number": true, "bool": true}
+
+// check returns an error if value is not of the type of the variable.
+func (v Var) check(value string) error {
+	var err error
+	switch v.Type {
+	case "", "string":
+	case "int":
+		_, err = strconv.Atoi(value)
+	case "number":
+		_, err = strconv.ParseFloat(value, 64)
+	case "bool":
+		_, err = strconv.ParseBool(value)
+	default:
+		return fmt.Errorf("unknown type `%s`", v.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("`%s` is not of type %s", value, v.Type)
+	}
+	return nil
+}
+
+// A binding holds the values of the variables and the arguments for all tasks
+// of a workflow run.
+type binding struct {
+	vars      map[string]string
+	secrets   map[string]string
+	arguments Arguments
+}
+
+// bind sets the variables from the command line arguments or their defaults.
+// All other command line arguments are merged over the with block of the
+// workflow. It returns ValidationErrors if a required variable is missing or a
+// value has the wrong type.
+func (workflow *Workflow) bind(arguments Arguments) (*binding, error) {
+	b := &binding{vars: map[string]string{}, secrets: map[string]string{}, arguments: Arguments{}}
+
+	var errs ValidationErrors
+	for _, name := range workflow.varNames() {
+		v := workflow.Vars[name]
+		value, ok := arguments[name]
+		if !ok {
+			if v.Required {
+				msg := fmt.Sprintf("missing required var %s", name)
+				if v.Help != "" {
+					msg += ": " + v.Help
+				}
+				errs = append(errs, workflow.validationError("", msg, "vars", name))
+				continue
+			}
+			expanded, err := expand(v.Default, b.value)
+			if err != nil {
+				errs = append(errs, workflow.validationError("", fmt.Sprintf("var %s: %s", name, err), "vars", name, "default"))
+				continue
+			}
+			value = expanded
+		}
+		if err := v.check(value); err != nil {
+			errs = append(errs, workflow.validationError("", fmt.Sprintf("var %s: %s", name, err), "vars", name))
+			continue
+		}
+		if v.Secret {
+			addSecret(value)
+			b.secrets[name] = value
+			continue
+		}
+		b.vars[name] = value
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	for _, name := range sortedKeys(workflow.Arguments) {
+		value, err := expand(workflow.Arguments[name], b.value)
+		if err != nil {
+			errs = append(errs, workflow.validationError("", fmt.Sprintf("with %s: %s", name, err), "with", name))
+		}
+		b.arguments[name] = value
+	}
+	for name, value := range arguments {
+		if _, ok := workflow.Vars[name]; !ok {
+			b.arguments[name] = value
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	b.arguments = dropRegistryArguments(b.arguments)
+	return b, nil
+}
+
+// value resolves vars and env expressions.
+func (b *binding) value(expression string) (string, error) {
+	switch {
+	case strings.HasPrefix(expression, "vars."):
+		if _, ok := b.secrets[strings.TrimPrefix(expression, "vars.")]; ok {
+			return "", fmt.Errorf("secret var `%s` cannot be used in expressions", strings.TrimPrefix(expression, "vars."))
+		}
+		value, ok := b.vars[strings.TrimPrefix(expression, "vars.")]
+		if !ok {
+			return "", fmt.Errorf("unknown var `%s`", strings.TrimPrefix(expression, "vars."))
+		}
+		return value, nil
+	case strings.HasPrefix(expression, "env."):
+		return os.Getenv(strings.TrimPrefix(expression, "env.")), nil
+	}
+	return "", fmt.Errorf("unknown expression `%s`", expression)
+}
+
+// ValidateArguments checks that all required variables of the workflow are
+// set by the arguments and that all values have the correct type, so a run
+// fails before any task is started.
+func (workflow *Workflow) ValidateArguments(arguments Arguments) error {
+	_, err := workflow.bind(arguments)
+	return err
+}
+
+func (workflow *Workflow) varNames() []string {
+	var names []string
+	for name := range workflow.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWorkflow_bind(t *testing.T) {
+	if err := os.Setenv("DAGGY_TEST_USER", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("DAGGY_TEST_USER")
+
+	workflow := Workflow{
+		Vars: map[string]Var{
+			"case":  {Required: true, Help: "case identifier"},
+			"limit": {Type: "int", Default: "10"},
+			"user":  {Default: "${{ env.DAGGY_TEST_USER }}"},
+		},
+		Arguments: Arguments{"case_id": "case-${{ vars.case }}", "limit": "${{ vars.limit }}", "keep": "yes"},
+	}
+
+	tests := []struct {
+		name          string
+		arguments     Arguments
+		wantVars      map[string]string
+		wantArguments Arguments
+		wantErr       bool
+	}{
+		{"defaults", Arguments{"case": "1"}, map[string]string{"case": "1", "limit": "10", "user": "alice"}, Arguments{"case_id": "case-1", "limit": "10", "keep": "yes"}, false},
+		{"merge", Arguments{"case": "1", "limit": "5", "keep": "no", "new": "x"}, map[string]string{"case": "1", "limit": "5", "user": "alice"}, Arguments{"case_id": "case-1", "limit": "5", "keep": "no", "new": "x"}, false},
+		{"missing required", Arguments{}, nil, nil, true},
+		{"wrong type", Arguments{"case": "1", "limit": "many"}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := workflow.bind(tt.arguments)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("bind() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if _, ok := err.(ValidationErrors); !ok {
+					t.Errorf("bind() error = %v, want ValidationErrors", err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(b.vars, tt.wantVars) {
+				t.Errorf("bind() vars = %v, want %v", b.vars, tt.wantVars)
+			}
+			if !reflect.DeepEqual(b.arguments, tt.wantArguments) {
+				t.Errorf("bind() arguments = %v, want %v", b.arguments, tt.wantArguments)
+			}
+		})
+	}
+}
+
+func TestWorkflow_RunVars(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggyvars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	workflow := Workflow{
+		Vars: map[string]Var{"case": {Required: true}},
+		Tasks: map[string]Task{
+			"a": {Type: "bash", Command: "echo ${{ vars.case }} > case.txt"},
+		},
+	}
+	workflow.SetupGraph()
+
+	if _, err := workflow.Run(context.Background(), storeDir, "", nil, nil); err == nil {
+		t.Fatal("Run() without required var succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "case.txt")); !os.IsNotExist(err) {
+		t.Error("task ran without required var")
+	}
+
+	if _, err := workflow.Run(context.Background(), storeDir, "", nil, Arguments{"case": "42"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(storeDir, "case.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "42\n" {
+		t.Errorf("case.txt = %q, want %q", b, "42\n")
+	}
+}
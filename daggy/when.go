@@ -0,0 +1,328 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A condition of a task, e.g. count(file, name=suffix:.pf) > 0 && vars.os == "windows".
+//
+// Conditions combine comparisons (==, !=, <, <=, >, >=) with &&, || and !.
+// Operands are numbers, quoted strings, true, false, references to
+// with.<argument>, vars.<name>, env.<name> and tasks.<task>.outputs.<name>,
+// and count(<type>, <attribute>=<value>, ...), the number of items of a type
+// that match the conditions like a filter entry. Values are compared as
+// numbers if both are numbers and as strings otherwise. A single value is
+// true unless it is empty, 0 or false.
+type when interface {
+	eval(env whenEnv) (string, error)
+}
+
+// whenEnv resolves the references and counts of a condition.
+type whenEnv interface {
+	value(reference string) (string, error)
+	count(itemType string, filter Filter) (int, error)
+}
+
+type whenLiteral string
+
+type whenReference string
+
+type whenCount struct {
+	itemType string
+	filter   Filter
+}
+
+type whenNot struct {
+	operand when
+}
+
+type whenBinary struct {
+	operator    string
+	left, right when
+}
+
+func (w whenLiteral) eval(whenEnv) (string, error) {
+	return string(w), nil
+}
+
+func (w whenReference) eval(env whenEnv) (string, error) {
+	return env.value(string(w))
+}
+
+func (w *whenCount) eval(env whenEnv) (string, error) {
+	count, err := env.count(w.itemType, w.filter)
+	return strconv.Itoa(count), err
+}
+
+func (w *whenNot) eval(env whenEnv) (string, error) {
+	value, err := w.operand.eval(env)
+	return strconv.FormatBool(!truthy(value)), err
+}
+
+func (w *whenBinary) eval(env whenEnv) (string, error) {
+	left, err := w.left.eval(env)
+	if err != nil {
+		return "", err
+	}
+	// short circuit, so counts are only run if needed
+	switch {
+	case w.operator == "&&" && !truthy(left):
+		return "false", nil
+	case w.operator == "||" && truthy(left):
+		return "true", nil
+	}
+	right, err := w.right.eval(env)
+	if err != nil {
+		return "", err
+	}
+
+	switch w.operator {
+	case "&&", "||":
+		return strconv.FormatBool(truthy(right)), nil
+	}
+	var cmp int
+	l, lok := parseNumber(left)
+	r, rok := parseNumber(right)
+	if lok && rok {
+		cmp = compareFloat(l, r)
+	} else {
+		cmp = strings.Compare(left, right)
+	}
+	result := map[string]bool{"==": cmp == 0, "!=": cmp != 0, "<": cmp < 0, "<=": cmp <= 0, ">": cmp > 0, ">=": cmp >= 0}[w.operator]
+	return strconv.FormatBool(result), nil
+}
+
+func truthy(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "0", "false":
+		return false
+	}
+	return true
+}
+
+// references returns all references of the condition.
+func references(w when) []string {
+	switch w := w.(type) {
+	case whenReference:
+		return []string{string(w)}
+	case *whenNot:
+		return references(w.operand)
+	case *whenBinary:
+		return append(references(w.left), references(w.right)...)
+	}
+	return nil
+}
+
+// whenParser is a recursive descent parser for conditions.
+type whenParser struct {
+	input string
+	pos   int
+}
+
+func parseWhen(input string) (when, error) {
+	p := &whenParser{input: input}
+	w, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.input) {
+		return nil, p.errorf("unexpected `%s`", p.input[p.pos:])
+	}
+	return w, nil
+}
+
+func (p *whenParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("when: "+format, args...)
+}
+
+func (p *whenParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// consume skips the token if it is next in the input.
+func (p *whenParser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *whenParser) or() (when, error) {
+	left, err := p.and()
+	for err == nil && p.consume("||") {
+		var right when
+		right, err = p.and()
+		left = &whenBinary{"||", left, right}
+	}
+	return left, err
+}
+
+func (p *whenParser) and() (when, error) {
+	left, err := p.unary()
+	for err == nil && p.consume("&&") {
+		var right when
+		right, err = p.unary()
+		left = &whenBinary{"&&", left, right}
+	}
+	return left, err
+}
+
+func (p *whenParser) unary() (when, error) {
+	p.skipSpace()
+	if !strings.HasPrefix(p.input[p.pos:], "!=") && p.consume("!") {
+		operand, err := p.unary()
+		return &whenNot{operand}, err
+	}
+	return p.comparison()
+}
+
+func (p *whenParser) comparison() (when, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for _, operator := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(operator) {
+			right, err := p.operand()
+			return &whenBinary{operator, left, right}, err
+		}
+	}
+	return left, nil
+}
+
+func (p *whenParser) operand() (when, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return nil, p.errorf("unexpected end")
+	}
+	switch c := p.input[p.pos]; {
+	case c == '(':
+		p.pos++
+		w, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("missing )")
+		}
+		return w, nil
+	case c == '"' || c == '\'':
+		s, err := p.quoted()
+		return whenLiteral(s), err
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && isWhenIdentifier(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	word := p.input[start:p.pos]
+	switch {
+	case word == "":
+		return nil, p.errorf("unexpected `%s`", p.input[p.pos:])
+	case word == "true" || word == "false":
+		return whenLiteral(word), nil
+	case word == "count" && p.consume("("):
+		return p.count()
+	}
+	if _, ok := parseNumber(word); ok {
+		return whenLiteral(word), nil
+	}
+	return whenReference(word), nil
+}
+
+func isWhenIdentifier(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.-", r)
+}
+
+// quoted reads a string in single or double quotes.
+func (p *whenParser) quoted() (string, error) {
+	quote := p.input[p.pos]
+	end := strings.IndexByte(p.input[p.pos+1:], quote)
+	if end < 0 {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.input[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return s, nil
+}
+
+// count reads the arguments of count(type, attribute=value, ...). Values can
+// be quoted if they contain commas or parentheses.
+func (p *whenParser) count() (when, error) {
+	var args []string
+	var arg strings.Builder
+	for {
+		if p.pos >= len(p.input) {
+			return nil, p.errorf("missing ) in count")
+		}
+		switch c := p.input[p.pos]; c {
+		case '"', '\'':
+			s, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			arg.WriteString(s)
+			continue
+		case ',', ')':
+			args = append(args, strings.TrimSpace(arg.String()))
+			arg.Reset()
+			p.pos++
+			if c == ',' {
+				continue
+			}
+		default:
+			arg.WriteByte(c)
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	if args[0] == "" {
+		return nil, p.errorf("count needs an item type")
+	}
+	w := &whenCount{itemType: args[0]}
+	if len(args) > 1 {
+		conditions := map[string]string{}
+		for _, arg := range args[1:] {
+			parts := strings.SplitN(arg, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+				return nil, p.errorf("count condition `%s` is not attribute=value", arg)
+			}
+			conditions[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+		w.filter = Filter{conditions}
+		if err := w.filter.Validate(); err != nil {
+			return nil, p.errorf("count: %s", err)
+		}
+	}
+	return w, nil
+}
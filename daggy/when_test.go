@@ -0,0 +1,162 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/forensicanalysis/forensicstore/goforensicstore"
+)
+
+type testEnv map[string]string
+
+func (env testEnv) value(reference string) (string, error) {
+	if value, ok := env[reference]; ok {
+		return value, nil
+	}
+	return "", fmt.Errorf("unknown %s", reference)
+}
+
+func (env testEnv) count(itemType string, filter Filter) (int, error) {
+	if itemType == "error" {
+		return 0, fmt.Errorf("count failed")
+	}
+	return len(filter), nil
+}
+
+func Test_parseWhen(t *testing.T) {
+	env := testEnv{"vars.os": "windows", "with.limit": "10", "tasks.a.outputs.count": "0", "env.EMPTY": ""}
+	tests := []struct {
+		when    string
+		want    bool
+		wantErr bool
+	}{
+		{"true", true, false},
+		{"false", false, false},
+		{"vars.os == 'windows'", true, false},
+		{`vars.os != "windows"`, false, false},
+		{"with.limit > 9", true, false},
+		{"with.limit > 9.5 && with.limit <= 10", true, false},
+		{"with.limit < 2", false, false},
+		{"'10' == 10.0", true, false},
+		{"'b' > 'a'", true, false},
+		{"tasks.a.outputs.count", false, false},
+		{"!tasks.a.outputs.count", true, false},
+		{"! (vars.os == 'linux' || env.EMPTY)", true, false},
+		{"count(file) > 0", false, false},
+		{"count(file, name=suffix:.pf) > 0", true, false},
+		{"count(file, name='a,b', size=gt:1MB) == 1", true, false},
+		{"false && count(error) > 0", false, false},
+		{"true || count(error) > 0", true, false},
+		{"count(error) > 0", false, true},
+		{"vars.missing", false, true},
+		{"(true", false, true},
+		{"true false", false, true},
+		{"count(, name=a)", false, true},
+		{"count(file, name)", false, true},
+		{"count(file, size=gt:big)", false, true},
+		{"'open", false, true},
+		{"==", false, true},
+		{"", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.when, func(t *testing.T) {
+			w, err := parseWhen(tt.when)
+			var value string
+			if err == nil {
+				value, err = w.eval(env)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWhen() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && truthy(value) != tt.want {
+				t.Errorf("eval() = %v, want %v", value, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkflow_RunWhen(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggywhen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+	store, err := goforensicstore.NewJSONLite(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Insert(map[string]interface{}{"type": "element", "name": "a.pf"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	workflow := Workflow{Tasks: map[string]Task{
+		"prefetch":  {Type: "bash", Command: "true", When: "count(element, name=suffix:.pf) > 0"},
+		"eventlogs": {Type: "bash", Command: "true", When: "count(element, name=suffix:.evtx) > 0"},
+		"report":    {Type: "bash", Command: "true", Requires: []string{"eventlogs"}},
+		"windows":   {Type: "bash", Command: "true", When: "with.os == 'windows'"},
+		"broken":    {Type: "bash", Command: "true", When: "count(element) > vars.missing", OnError: OnErrorContinue},
+	}}
+	workflow.SetupGraph()
+
+	results, err := workflow.Run(context.Background(), storeDir, "", nil, Arguments{"os": "windows"})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := map[string]Status{
+		"prefetch":  StatusSucceeded,
+		"eventlogs": StatusSkipped,
+		"report":    StatusSkipped,
+		"windows":   StatusSucceeded,
+		"broken":    StatusFailed,
+	}
+	for name, status := range want {
+		if results[name].Status != status {
+			t.Errorf("task %s: status = %s (%s), want %s", name, results[name].Status, results[name].Reason, status)
+		}
+	}
+	if reason := results["eventlogs"].Reason; reason != "condition `count(element, name=suffix:.evtx) > 0` is false" {
+		t.Errorf("eventlogs reason = %s", reason)
+	}
+	if reason := results["report"].Reason; reason != "requirement eventlogs was skipped" {
+		t.Errorf("report reason = %s", reason)
+	}
+
+	journal, err := OpenJournal(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := journal.Tasks["eventlogs"].Status; got != StatusSkipped {
+		t.Errorf("journal eventlogs status = %s, want %s", got, StatusSkipped)
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, JournalFile)); err != nil {
+		t.Error(err)
+	}
+}
@@ -22,78 +22,112 @@
 package daggy
 
 import (
-	"fmt"
+	"context"
 	"log"
 
 	"github.com/hashicorp/terraform/dag"
-	"github.com/hashicorp/terraform/tfdiags"
 	"github.com/pkg/errors"
 )
 
 // Workflow can be used to parse workflow.yml files.
 type Workflow struct {
-	Tasks      map[string]Task `yaml:"tasks"`
-	Arguments  Arguments       `yaml:"with"`
-	graph      *dag.AcyclicGraph
-	workingDir string
-	pluginDir  string
-	plugins    map[string]Plugin
+	Description string                   `yaml:"description"`
+	Include     []string                 `yaml:"include"`
+	Tasks       map[string]Task          `yaml:"tasks"`
+	Arguments   Arguments                `yaml:"with"`
+	Vars        map[string]Var           `yaml:"vars"`
+	FailFast    bool                     `yaml:"fail_fast"`
+	MaxParallel int                      `yaml:"max_parallel"`
+	Resources   map[string]ResourceClass `yaml:"resources"`
+	Container   Container                `yaml:"container"`
+	Resume      bool                     `yaml:"-"`
+	NoCache     bool                     `yaml:"-"`
+	graph       *dag.AcyclicGraph
+	slots       chan struct{}
+	classSlots  map[string]chan struct{}
+	source      *source
 }
 
-// SetupGraph creates a direct acyclic graph of tasks.
+// SetupGraph creates a direct acyclic graph of tasks. It must be called before
+// the workflow is run. Tasks with a matrix are replaced by a task for every
+// combination of the matrix values and a group task with the name of the task
+// that requires all of them.
 func (workflow *Workflow) SetupGraph() {
-	// Create the dag
 	setupLogging()
+	workflow.Tasks = expandMatrices(workflow.Tasks)
+	workflow.graph = taskGraph(workflow.Tasks)
+	for _, task := range workflow.Tasks {
+		if task.workflow != nil {
+			task.workflow.SetupGraph()
+		}
+	}
+
+	// limit the number of tasks running at the same time over all runs
+	workflow.setupLimits()
+}
+
+func taskGraph(tasks map[string]Task) *dag.AcyclicGraph {
+	// Create the dag
 	graph := dag.AcyclicGraph{}
-	tasks := map[string]Task{}
-	for name, task := range workflow.Tasks {
+	for name := range tasks {
 		graph.Add(name)
-		tasks[name] = task
 	}
 
 	// add edges / requirements
-	for name, task := range workflow.Tasks {
+	for name, task := range tasks {
 		for _, requirement := range task.Requires {
 			graph.Connect(dag.BasicEdge(requirement, name))
 		}
 	}
-
-	workflow.graph = &graph
+	return &graph
 }
 
-// Run walks the direct acyclic graph to execute each task.
-func (workflow *Workflow) Run(workingDir, pluginDir string, plugins map[string]Plugin, arguments Arguments) error {
-	workflow.workingDir = workingDir
-	workflow.pluginDir = pluginDir
-	workflow.Arguments = arguments
-	workflow.plugins = plugins
+// Run walks the direct acyclic graph to execute each task. Canceling ctx
+// aborts all running tasks and skips the remaining ones. The state of every
+// task is recorded in the journal of the forensicstore. If Resume is set,
+// tasks that already succeeded with the same inputs are skipped.
+//
+// The arguments set the vars of the workflow and are merged over its with
+// block. Run fails before any task is started if a required var is missing.
+//
+// Run returns the results of all tasks and an error if any task with the
+// on_error policy fail failed. Run can be called concurrently for different
+// forensicstores; if MaxParallel is set, at most MaxParallel tasks are executed
+// at the same time over all calls. Tasks of a resource class are additionally
+// limited by the max_concurrent of the class.
+func (workflow *Workflow) Run(ctx context.Context, workingDir, pluginDir string, plugins map[string]Plugin, arguments Arguments) (Results, error) {
+	if workflow.graph == nil {
+		return nil, errors.New("workflow graph is not set up")
+	}
 
-	w := &dag.Walker{Callback: func(v dag.Vertex) tfdiags.Diagnostics {
-		err := workflow.runTask(v.(string))
-		if err != nil {
-			return tfdiags.Diagnostics{tfdiags.Sourceless(tfdiags.Error, fmt.Sprint(v.(string)), err.Error())}
-		}
-		return nil
-	}}
-	w.Update(workflow.graph)
-	return w.Wait().Err()
-}
+	b, err := workflow.bind(arguments)
+	if err != nil {
+		return nil, err
+	}
 
-func (workflow *Workflow) runTask(taskName string) (err error) {
-	task := workflow.Tasks[taskName]
+	journal, err := OpenJournal(workingDir)
+	if err != nil {
+		return nil, err
+	}
 
-	log.Println("Start", taskName)
-	defer log.Println("End", taskName)
-	switch task.Type {
-	case "bash":
-		return bash(task.Command, task.Arguments, task.Filter, workflow)
-	case "docker":
-		return docker(task.Image, task.Command, task.Arguments, task.Filter, true, workflow)
-	case "dockerfile":
-		return dockerfile(task.Dockerfile, task.Arguments, task.Filter, workflow)
-	case "plugin":
-		return plugin(task.Command, task.Arguments, task.Filter, workflow)
-	default:
-		return errors.New("unknown type")
+	r := &run{
+		workflow:   workflow,
+		workingDir: workingDir,
+		pluginDir:  pluginDir,
+		plugins:    plugins,
+		arguments:  b.arguments,
+		vars:       b.vars,
+		secrets:    b.secrets,
+		journal:    journal,
+		id:         newRunID(),
+		images:     &pulledImages{ids: map[string]string{}},
+		results:    Results{},
+		resumed:    map[string]bool{},
+		blocking:   map[string]bool{},
+	}
+	results, err := r.walk(ctx)
+	if err := journal.Flush(); err != nil {
+		log.Println("could not write journal:", err)
 	}
+	return results, err
 }
@@ -22,11 +22,15 @@
 package daggy
 
 import (
+	"context"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/otiai10/copy"
 
@@ -92,7 +96,7 @@ func (*ExamplePlugin) Description() string {
 }
 
 // Run does nothing for the example plugin.
-func (*ExamplePlugin) Run(string, Arguments, Filter) error {
+func (*ExamplePlugin) Run(context.Context, string, Arguments, Filter) error {
 	return nil
 }
 
@@ -122,7 +126,9 @@ func Test_processJob(t *testing.T) {
 		{"unknown type", "example1.forensicstore", args{"testtask", Task{Type: "foo", Command: "foo"}}, "", 0, true},
 		{"bash", "example1.forensicstore", args{"test bash", Task{Type: "bash", Command: "true"}}, "", 0, false},
 		{"bash fail", "example1.forensicstore", args{"test bash", Task{Type: "bash", Command: "false"}}, "", 0, true},
+		{"bash timeout", "example1.forensicstore", args{"test bash", Task{Type: "bash", Command: "sleep 10 | cat", Timeout: 100 * time.Millisecond}}, "", 0, true},
 		{"docker", "example1.forensicstore", args{"testtask", Task{Type: "docker", Image: "alpine", Command: "true"}}, "", 0, false},
+		{"docker fail", "example1.forensicstore", args{"testtask", Task{Type: "docker", Image: "alpine", Command: "false"}}, "", 0, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -131,7 +137,7 @@ func Test_processJob(t *testing.T) {
 
 			plugins := map[string]Plugin{"example": &ExamplePlugin{}}
 
-			if err := workflow.Run(filepath.Join(storeDir, tt.storeName), pluginDir, plugins, nil); (err != nil) != tt.wantErr {
+			if _, err := workflow.Run(context.Background(), filepath.Join(storeDir, tt.storeName), pluginDir, plugins, nil); (err != nil) != tt.wantErr {
 				t.Errorf("runTask() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
@@ -155,3 +161,111 @@ func Test_processJob(t *testing.T) {
 		})
 	}
 }
+
+func TestWorkflow_RunOnError(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "daggyonerror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	tests := []struct {
+		name     string
+		onError  string
+		failFast bool
+		want     map[string]Status
+		wantErr  bool
+	}{
+		{"fail", OnErrorFail, false, map[string]Status{"broken": StatusFailed, "dependent": StatusSkipped, "independent": StatusSucceeded}, true},
+		{"continue", OnErrorContinue, false, map[string]Status{"broken": StatusFailed, "dependent": StatusSucceeded, "independent": StatusSucceeded}, false},
+		{"skip dependents", OnErrorSkipDependents, false, map[string]Status{"broken": StatusFailed, "dependent": StatusSkipped, "independent": StatusSucceeded}, false},
+		{"fail fast", OnErrorFail, true, map[string]Status{"broken": StatusFailed, "dependent": StatusSkipped}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow := Workflow{Tasks: map[string]Task{
+				"broken":      {Type: "bash", Command: "false", OnError: tt.onError},
+				"dependent":   {Type: "bash", Command: "true", Requires: []string{"broken"}},
+				"independent": {Type: "bash", Command: "sleep 5 | cat"},
+			}, FailFast: tt.failFast}
+			if !tt.failFast {
+				workflow.Tasks["independent"] = Task{Type: "bash", Command: "true"}
+			}
+			workflow.SetupGraph()
+
+			results, err := workflow.Run(context.Background(), storeDir, "", nil, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for name, want := range tt.want {
+				if results[name].Status != want {
+					t.Errorf("Run() %s status = %v (%s), want %v", name, results[name].Status, results[name].Reason, want)
+				}
+			}
+			if tt.failFast && results["independent"].Status == StatusSucceeded {
+				t.Errorf("Run() independent task was not canceled")
+			}
+			if results["dependent"].Status == StatusSkipped && results["dependent"].Reason != "requirement broken failed" {
+				t.Errorf("Run() dependent reason = %s", results["dependent"].Reason)
+			}
+		})
+	}
+}
+
+func TestWorkflow_RunParallel(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "daggyparallel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	workflow := Workflow{Tasks: map[string]Task{
+		"first":  {Type: "bash", Command: "pwd > first.txt"},
+		"second": {Type: "bash", Command: "cat first.txt > second.txt", Requires: []string{"first"}},
+	}, MaxParallel: 2}
+	workflow.SetupGraph()
+
+	var storeDirs []string
+	for _, name := range []string{"a", "b", "c", "d"} {
+		storeDir := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(storeDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		storeDirs = append(storeDirs, storeDir)
+	}
+
+	var wg sync.WaitGroup
+	for _, storeDir := range storeDirs {
+		wg.Add(1)
+		go func(storeDir string) {
+			defer wg.Done()
+			results, err := workflow.Run(context.Background(), storeDir, "", nil, nil)
+			if err != nil {
+				t.Errorf("Run() error = %v", err)
+				return
+			}
+			if len(results) != 2 {
+				t.Errorf("Run() got %d results, want 2", len(results))
+			}
+		}(storeDir)
+	}
+	wg.Wait()
+
+	for _, storeDir := range storeDirs {
+		b, err := ioutil.ReadFile(filepath.Join(storeDir, "second.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := filepath.EvalSymlinks(strings.TrimSpace(string(b)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := filepath.EvalSymlinks(storeDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Run() task ran in %s, want %s", got, want)
+		}
+	}
+}
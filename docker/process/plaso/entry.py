@@ -31,14 +31,36 @@ import forensicstore
 LOGGER = logging.getLogger(__name__)
 
 
+def parse_filter(text):
+    """ Parse a filter like "type=file,name=System.evtx" where commas, equal signs
+    and backslashes can be escaped by a backslash. """
+    conditions = {}
+    elements = [[""]]
+    escaped = False
+    for char in text:
+        if escaped:
+            elements[-1][-1] += char
+            escaped = False
+        elif char == "\\":
+            escaped = True
+        elif char == ",":
+            elements.append([""])
+        elif char == "=" and len(elements[-1]) == 1:
+            elements[-1].append("")
+        else:
+            elements[-1][-1] += char
+    for element in elements:
+        if len(element) != 2:
+            raise ValueError("invalid filter element %s" % "".join(element))
+        conditions[element[0]] = element[1]
+    return conditions
+
+
 class StoreDictKeyPair(argparse.Action):
     # pylint: disable=too-few-public-methods
 
     def __call__(self, parser, namespace, values, option_string=None):
-        new_dict = {}
-        for element in values.split(","):
-            key, value = element.split("=")
-            new_dict[key] = value
+        new_dict = parse_filter(values)
         if hasattr(namespace, self.dest):
             dict_list = getattr(namespace, self.dest)
             if dict_list is not None:
@@ -7,7 +7,7 @@ require (
 	github.com/Microsoft/go-winio v0.4.14 // indirect
 	github.com/Velocidex/ordereddict v0.0.0-20191106020901-97c468e5e403
 	github.com/containerd/containerd v1.3.2 // indirect
-	github.com/docker/distribution v2.7.1+incompatible // indirect
+	github.com/docker/distribution v2.7.1+incompatible
 	github.com/docker/docker v1.4.2-0.20191108192604-36ffe9edc2b3
 	github.com/docker/go-connections v0.4.0 // indirect
 	github.com/docker/go-units v0.4.0 // indirect
@@ -17,13 +17,15 @@ require (
 	github.com/hashicorp/terraform v0.12.17
 	github.com/imdario/mergo v0.3.7
 	github.com/markbates/pkger v0.15.0
+	github.com/mattn/go-sqlite3 v1.11.0
 	github.com/morikuni/aec v1.0.0 // indirect
 	github.com/opencontainers/go-digest v1.0.0-rc1 // indirect
 	github.com/opencontainers/image-spec v1.0.1 // indirect
 	github.com/otiai10/copy v1.0.2
 	github.com/pkg/errors v0.8.1
 	github.com/spf13/cobra v0.0.5
-	gopkg.in/yaml.v2 v2.2.7
+	gopkg.in/yaml.v2 v2.2.7 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 	gotest.tools v2.2.0+incompatible // indirect
 	www.velocidex.com/golang/evtx v0.0.1
 	www.velocidex.com/golang/go-prefetch v0.0.0-20190703150313-0469fa2f85cf
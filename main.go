@@ -29,6 +29,10 @@
 //
 //     forensicworkflows --workflow workflow.yml test/data/example1.forensicstore
 //
+// Workflows can be checked for errors without running them:
+//
+//     forensicworkflows validate --workflow workflow.yml
+//
 // Workflow format
 //
 // The workflow.yml file contains a list of tasks like the following:
@@ -66,7 +70,12 @@
 // Docker
 //
 // Run a docker container. The forensicstore is located at '/store' and the plugin
-// folder is located at '/process'. Example:
+// folder is located at '/process'. The output of the container is logged with
+// the task name as prefix while it runs. A container that exits with a non-zero
+// code fails the task. Containers are labeled with the workflow, task, store
+// and run and removed after the task, unless keep_container is set.
+// Containers left behind by crashed runs are removed with
+// forensicworkflows docker prune. Example:
 //
 //     docker_task:
 //         type: docker
@@ -82,6 +91,345 @@
 //         type: dockerfile
 //         dockerfile: jq
 //         command: echo Dockerfile
+//
+// Timeouts
+//
+// Every task can limit its runtime with a timeout. Tasks that exceed the
+// timeout are aborted and fail. Interrupting forensicworkflows aborts all
+// running tasks and stops started containers. Example:
+//
+//     slow_task:
+//         type: bash
+//         command: sleep 600
+//         timeout: 5m
+//
+// Resuming workflows
+//
+// The state of every task is recorded in the workflow-journal.json file in the
+// forensicstore. The journal is written at most once per second and when the
+// workflow ends. If a workflow fails, it can be resumed with the --resume flag.
+// Tasks that succeeded before with the same definition and arguments are then
+// skipped, e.g.:
+//
+//     forensicworkflows --workflow workflow.yml --resume test/data/example1.forensicstore
+//
+// Error handling
+//
+// By default a failing task fails the workflow and all tasks that require it
+// are skipped, while independent tasks are still run. This can be changed per
+// task with on_error: continue runs the dependent tasks anyway and
+// skip-dependents skips them without failing the workflow. With fail_fast set
+// on the workflow, the first failing task aborts the whole workflow. A summary
+// of all tasks is printed at the end. Example:
+//
+//     fail_fast: false
+//     tasks:
+//         shimcache:
+//             type: plugin
+//             command: shimcache
+//             on_error: continue
+//
+// Reports
+//
+// The process, import and export commands can write a report of all tasks on
+// all forensicstores as json or JUnit XML. The report contains the status,
+// duration, error message, captured output and number of inserted items of
+// every task. The number of items is left out if other tasks changed the
+// forensicstore at the same time, except for built-in plugins, which count
+// their inserts. The command exits with a non-zero status after writing the
+// report if any task failed or the workflow was canceled, e.g.:
+//
+//     forensicworkflows --workflow workflow.yml --report junit --report-file report.xml test/data/example1.forensicstore
+//
+// Parallel processing
+//
+// Multiple forensicstores can be processed at the same time with the --parallel
+// flag. The number of tasks running at the same time over all forensicstores
+// can be limited with max_parallel in the workflow file, e.g.:
+//
+//     forensicworkflows --workflow workflow.yml --parallel 4 store1.forensicstore store2.forensicstore
+//
+// Resource limits
+//
+// By default all tasks whose requirements are met run at the same time. The
+// total number of running tasks can be limited with max_parallel or the
+// --max-parallel flag. Tasks can be assigned to a resource class, whose
+// max_concurrent limits the number of running tasks of that class over all
+// forensicstores. A task with max_concurrent but without class is limited on
+// its own. Example:
+//
+//     max_parallel: 8
+//     resources:
+//         heavy:
+//             max_concurrent: 1
+//     tasks:
+//         plaso:
+//             type: docker
+//             image: log2timeline/plaso
+//             resources:
+//                 class: heavy
+//
+// Retries
+//
+// Tasks that fail transiently can be retried. A failed task is run again up to
+// retries times. The delay before the next attempt starts at retry_delay
+// (default 1s) and doubles with every attempt. The number of attempts is shown
+// in the summary and reports. Example:
+//
+//     plaso:
+//         type: docker
+//         image: log2timeline/plaso
+//         retries: 3
+//         retry_delay: 10s
+//
+// Dry run
+//
+// The --dry-run flag prints the execution plan without running any task or
+// accessing the forensicstore. The plan lists the tasks in levels, where all
+// tasks of a level can run in parallel, together with the resolved command line
+// and the source of every plugin, e.g.:
+//
+//     forensicworkflows --workflow workflow.yml --dry-run test/data/example1.forensicstore
+//
+// Graph
+//
+// The graph command prints the task graph of a workflow in the dot or mermaid
+// format. Tasks are colored by their type or, given the journal of a
+// forensicstore (--journal) or a json report (--report-file), by the status of
+// their last execution, e.g.:
+//
+//     forensicworkflows graph --workflow workflow.yml --format dot --journal test/data/example1.forensicstore | dot -Tpng > workflow.png
+//
+// Filters
+//
+// Tasks can select items with a filter. An item is selected if it matches any
+// entry of the filter and it matches an entry if all attributes match.
+// Attributes can be paths into nested objects like attributes.created. Values
+// can use the operators eq:, prefix:, suffix:, contains:, regex:, in: (values
+// separated by |) and gt:, ge:, lt:, le: for numbers, sizes like 1MB or 4KiB
+// and times. A leading ! negates the operator. Values without operator keep
+// their previous meaning. Script plugins receive the same filter in the
+// --filter argument. Example:
+//
+//     eventlogs:
+//         type: plugin
+//         command: eventlogs
+//         filter:
+//             - name: suffix:.evtx
+//               size: gt:1MB
+//               origin.path: "!contains:System32"
+//
+// Outputs
+//
+// Tasks can pass values to the tasks that require them. A task declares its
+// outputs, which are read from a JSON object printed on stdout, from the values
+// returned by a Go plugin or from a file in the forensicstore. The whole file
+// is used unless key selects a value of the JSON object in the file. Other
+// tasks reference outputs in command and with as
+// ${{ tasks.<task>.outputs.<name> }}. Outputs in a command are quoted, so they
+// are always a single argument. The runkeys script prints the number of run
+// keys as count, e.g.:
+//
+//     runkeys:
+//         type: plugin
+//         command: runkeys
+//         outputs:
+//             count:
+//     hostname:
+//         type: bash
+//         command: hostname > hostname.txt
+//         outputs:
+//             name:
+//                 from: file
+//                 path: hostname.txt
+//     summary:
+//         type: bash
+//         command: echo ${{ tasks.runkeys.outputs.count }} run keys on ${{ tasks.hostname.outputs.name }}
+//         requires:
+//             - runkeys
+//             - hostname
+//
+// Variables
+//
+// Workflows can declare vars, which are set with flags on the command line,
+// e.g. --case 42. Vars can have a default, a type (string, int, number or
+// bool) and a help text. Runs without a required var or with a value of the
+// wrong type fail before any task is started. Other flags are merged over the
+// with block of the workflow. The with blocks and commands can use vars as
+// ${{ vars.<name> }} and environment variables as ${{ env.<name> }}. Example:
+//
+//     vars:
+//         case:
+//             required: true
+//             help: case identifier
+//         max_size:
+//             type: int
+//             default: 100
+//     with:
+//         case: ${{ vars.case }}
+//         analyst: ${{ env.USER }}
+//     tasks:
+//         large_files:
+//             type: bash
+//             command: echo ${{ vars.max_size }}
+//
+// Conditions
+//
+// Tasks can be skipped with a when condition, which is evaluated before the
+// task starts. Conditions compare values with ==, !=, <, <=, > and >= and
+// combine them with &&, || and !. Values are numbers, quoted strings, the
+// arguments as with.<name>, vars, environment variables, outputs of required
+// tasks and count(type, attribute=value, ...), the number of items in the
+// forensicstore that match the conditions. Tasks with a false condition and
+// their dependents are skipped without failing the workflow. Example:
+//
+//     prefetch:
+//         type: plugin
+//         command: prefetch
+//         when: count(file, name=suffix:.pf) > 0
+//     usb:
+//         type: plugin
+//         command: usb
+//         when: with.os == "windows"
+//
+// Matrix
+//
+// A task with a matrix runs once for every combination of the matrix values.
+// The values are passed as arguments and can be used as ${{ matrix.<key> }}.
+// A task with for_each runs once for every item of a type in the forensicstore
+// that matches the filter. The items are selected when the task starts, so
+// items added by the tasks it requires are included, but not items added by the
+// runs themselves. Every run only gets its item in the filter and can use the
+// item attributes as ${{ item.<attribute> }}. Item attributes in the command
+// are quoted, so they are always a single argument. Tasks that require a matrix
+// or for_each task wait for all of its runs.
+// Example:
+//
+//     hash:
+//         type: plugin
+//         command: hash
+//         matrix:
+//             algorithm: [md5, sha1]
+//     carve:
+//         type: docker
+//         image: carve
+//         command: carve ${{ item.name }}
+//         for_each:
+//             type: file
+//             filter:
+//                 - name: suffix:.img
+//         resources:
+//             max_concurrent: 2
+//
+// Composition
+//
+// Tasks can be shared between workflow files with include. The tasks of the
+// included files are added to the workflow; their vars, with and resources are
+// added unless the including file defines them. A task name must only be
+// defined once. Paths are relative to the including file.
+//
+// A task with the type workflow runs another workflow file as a single task. The
+// nested workflow gets the with block of the task as arguments and its tasks are
+// recorded as <task>/<nested task>. Example:
+//
+//     include:
+//         - common.yml
+//     tasks:
+//         hash:
+//             type: workflow
+//             workflow: hash.yml
+//             with:
+//                 algorithm: sha1
+//             requires: [prefetch]
+//
+// Built-in workflows
+//
+// Standard workflows are built into forensicworkflows and can be used instead of
+// a workflow file with builtin:<name>. forensicworkflows workflows list prints
+// all built-in workflows, e.g. windows-triage, timeline and persistence.
+//
+//     forensicworkflows workflows list
+//     forensicworkflows --workflow builtin:windows-triage test/data/example1.forensicstore
+//
+// Caching
+//
+// Tasks whose inputs did not change since their last successful run are skipped
+// with the status cached. The cache key of a task is derived from its
+// definition, the arguments, the digest of its plugin, script or image and a hash
+// of the items it reads. The item types a task reads are declared with inputs;
+// built-in plugins like prefetch and eventlogs declare them themselves. Tasks
+// without known inputs are always run. The --no-cache flag runs all tasks.
+// Example:
+//
+//     hashes:
+//         type: docker
+//         image: hasher
+//         inputs: [file]
+//         filter:
+//             - name: "%.exe"
+//
+// Container isolation
+//
+// Docker and dockerfile tasks can be isolated with memory, cpus, network (none
+// or bridge), user, read_only_store, which mounts the forensicstore read-only,
+// and env. The container section of the workflow sets defaults for all tasks,
+// which tasks can override. The plugin folder and the files read by imports
+// are always mounted read-only. Example:
+//
+//     container:
+//         network: none
+//         memory: 2GiB
+//         read_only_store: true
+//     tasks:
+//         plaso:
+//             type: docker
+//             image: log2timeline/plaso
+//             cpus: 2
+//             read_only_store: false
+//             env:
+//                 TZ: UTC
+//
+// Images
+//
+// Docker tasks pull their image once per workflow run by default. The pull
+// policy can be set to always, if-not-present or never with pull in the
+// container section of the workflow or a task. The --pull flag overrides the
+// policy of the workflow. With never, a task fails if its image is not present.
+// Dockerfile tasks follow the policy for their base images. For hosts without
+// registry access, the images of a workflow, including the base images of its
+// dockerfiles, can be saved into a tar archive and loaded on the target host,
+// e.g.:
+//
+//     forensicworkflows images save --workflow builtin:windows-triage --file images.tar
+//     forensicworkflows images load --file images.tar
+//     forensicworkflows --workflow builtin:windows-triage --pull never test/data/example1.forensicstore
+//
+// Secrets
+//
+// Images are pulled with the credentials of the docker config file
+// (~/.docker/config.json or $DOCKER_CONFIG), including credential stores and
+// credential helpers per registry. The docker-server, docker-user and
+// docker-password arguments of older versions are ignored and not passed to
+// tasks.
+//
+// Vars with secret: true are never passed as command line arguments. A task gets
+// them only if it lists them in secrets: bash, script and container tasks as
+// environment variables, e.g. VT_API_KEY for vt-api-key, built-in plugins
+// with their arguments and nested workflows as their secret vars. Secret vars
+// cannot be used in expressions. Their values are replaced by *** in logs,
+// reports and the journal. To keep them out of the command line of
+// forensicworkflows as well, set them from the environment. Example:
+//
+//     vars:
+//         vt-api-key:
+//             secret: true
+//             default: ${{ env.VT_API_KEY }}
+//     tasks:
+//         virustotal:
+//             type: docker
+//             image: virustotal
+//             command: lookup --key-env VT_API_KEY
+//             secrets: [vt-api-key]
 package main
 
 import (
@@ -100,7 +448,7 @@ import (
 
 func main() {
 	rootCmd := cmd.Process()
-	rootCmd.AddCommand(cmd.Import(), cmd.Export())
+	rootCmd.AddCommand(cmd.Import(), cmd.Export(), cmd.Validate(), cmd.Graph(), cmd.Workflows(), cmd.Docker(), cmd.Images())
 	rootCmd.Use = "forensicworkflows"
 	rootCmd.FParseErrWhitelist = cobra.FParseErrWhitelist{UnknownFlags: true}
 	if err := rootCmd.Execute(); err != nil {
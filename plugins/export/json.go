@@ -22,6 +22,7 @@
 package export
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"os"
@@ -40,26 +41,29 @@ func (*JSONPlugin) Description() string {
 	return "Export json files"
 }
 
-func (*JSONPlugin) Run(url string, data daggy.Arguments, filter daggy.Filter) error {
+func (*JSONPlugin) Run(ctx context.Context, url string, data daggy.Arguments, filter daggy.Filter) error {
 	store, err := goforensicstore.NewJSONLite(url)
 	if err != nil {
 		return err
 	}
+	defer store.Close()
 
 	file := data.Get("file")
 	if file == "" {
 		return errors.New("missing 'file' in args")
 	}
 
-	items, err := store.All()
+	it, err := daggy.MatchIterator(url, filter)
 	if err != nil {
 		return err
 	}
+	defer it.Close()
 
 	f, err := os.Create(file)
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
 	_, err = f.WriteString("[\n")
 	if err != nil {
@@ -67,17 +71,21 @@ func (*JSONPlugin) Run(url string, data daggy.Arguments, filter daggy.Filter) er
 	}
 
 	encoder := json.NewEncoder(f)
-	for _, item := range items {
-		if filter.Match(item) {
-			err = encoder.Encode(item)
-			if err != nil {
-				return err
-			}
-			_, err = f.WriteString(",\n")
-			if err != nil {
-				return err
-			}
+	for it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		err = encoder.Encode(it.Item())
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString(",\n")
+		if err != nil {
+			return err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
 	}
 
 	_, err = f.WriteString("]\n")
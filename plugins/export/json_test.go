@@ -22,6 +22,7 @@
 package export
 
 import (
+	"context"
 	"io/ioutil"
 	"log"
 	"os"
@@ -106,7 +107,7 @@ func TestJSONPlugin_Run(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			js := &JSONPlugin{}
-			if err := js.Run(filepath.Join(storeDir, "data", tt.args.url), tt.args.data, nil); (err != nil) != tt.wantErr {
+			if err := js.Run(context.Background(), filepath.Join(storeDir, "data", tt.args.url), tt.args.data, nil); (err != nil) != tt.wantErr {
 				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
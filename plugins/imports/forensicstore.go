@@ -22,6 +22,7 @@
 package imports
 
 import (
+	"context"
 	"errors"
 	"io"
 	"path/filepath"
@@ -45,7 +46,7 @@ func (*JSONLitePlugin) Description() string {
 	return "Import forensicstore files"
 }
 
-func (*JSONLitePlugin) Run(url string, data daggy.Arguments, filter daggy.Filter) error {
+func (*JSONLitePlugin) Run(ctx context.Context, url string, data daggy.Arguments, filter daggy.Filter) error {
 	store, err := goforensicstore.NewJSONLite(url)
 	if err != nil {
 		return err
@@ -56,11 +57,11 @@ func (*JSONLitePlugin) Run(url string, data daggy.Arguments, filter daggy.Filter
 		return errors.New("missing 'file' in args")
 	}
 
-	return jsonLite(store.Store, file, filter)
+	return jsonLite(ctx, store.Store, file, filter)
 }
 
 // jsonLite merges another JSONLite into this one.
-func jsonLite(db gostore.Store, url string, filter daggy.Filter) (err error) {
+func jsonLite(ctx context.Context, db gostore.Store, url string, filter daggy.Filter) (err error) {
 	// TODO: import items with "_path" on sublevel"…
 	// TODO: import does not need to unflatten and flatten
 
@@ -68,14 +69,20 @@ func jsonLite(db gostore.Store, url string, filter daggy.Filter) (err error) {
 	if err != nil {
 		return err
 	}
-	items, err := importStore.All()
+	defer importStore.Close()
+
+	it, err := daggy.MatchIterator(url, filter)
 	if err != nil {
 		return err
 	}
-	for _, item := range items {
-		if !filter.Match(item) {
-			continue
+	defer it.Close()
+
+	inserter := daggy.NewBatchInserter(ctx, db)
+	for it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		item := it.Item()
 
 		for field := range item {
 			item := item
@@ -88,7 +95,10 @@ func jsonLite(db gostore.Store, url string, filter daggy.Filter) (err error) {
 				if err != nil {
 					return err
 				}
-				if _, err = io.Copy(writer, reader); err != nil {
+				_, err = io.Copy(writer, reader)
+				writer.Close()
+				reader.Close()
+				if err != nil {
 					return err
 				}
 				if err := mergo.Merge(&item, gojsonlite.Item{field: dstPath}); err != nil {
@@ -96,10 +106,12 @@ func jsonLite(db gostore.Store, url string, filter daggy.Filter) (err error) {
 				}
 			}
 		}
-		_, err = db.Insert(item)
-		if err != nil {
+		if err := inserter.Insert(item); err != nil {
 			return err
 		}
 	}
-	return err
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return inserter.Flush()
 }
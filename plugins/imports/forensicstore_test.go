@@ -22,6 +22,7 @@
 package imports
 
 import (
+	"context"
 	"log"
 	"path/filepath"
 	"testing"
@@ -55,7 +56,7 @@ func TestJSONLitePlugin_Run(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			js := &JSONLitePlugin{}
 			url := filepath.Join(storeDir, tt.args.url)
-			err := js.Run(url, tt.args.data, nil)
+			err := js.Run(context.Background(), url, tt.args.data, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -22,9 +22,10 @@
 package imports
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
+	"os"
 
 	"github.com/forensicanalysis/forensicstore/goforensicstore"
 	"github.com/forensicanalysis/forensicstore/gojsonlite"
@@ -41,7 +42,7 @@ func (*JSONPlugin) Description() string {
 	return "Import json files"
 }
 
-func (*JSONPlugin) Run(url string, data daggy.Arguments, filter daggy.Filter) error {
+func (*JSONPlugin) Run(ctx context.Context, url string, data daggy.Arguments, filter daggy.Filter) error {
 	store, err := goforensicstore.NewJSONLite(url)
 	if err != nil {
 		return err
@@ -57,26 +58,39 @@ func (*JSONPlugin) Run(url string, data daggy.Arguments, filter daggy.Filter) er
 		return errors.New("missing 'file' in args")
 	}
 
-	b, err := ioutil.ReadFile(file) // #nosec
+	f, err := os.Open(file) // #nosec
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
-	var items []gojsonlite.Item
-	err = json.Unmarshal(b, &items)
-	if err != nil {
-		return errors.New("imported json must have a top level array containing objects")
+	errFormat := errors.New("imported json must have a top level array containing objects")
+
+	// decode the items one by one instead of the whole array
+	decoder := json.NewDecoder(f)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return errFormat
 	}
 
-	for _, item := range items {
+	inserter := daggy.NewBatchInserter(ctx, store)
+	for decoder.More() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var item gojsonlite.Item
+		if err := decoder.Decode(&item); err != nil || item == nil {
+			return errFormat
+		}
 		item["type"] = itemType
 		if filter.Match(item) {
-			_, err = store.Insert(item)
-			if err != nil {
+			if err := inserter.Insert(item); err != nil {
 				return err
 			}
 		}
 	}
+	if _, err := decoder.Token(); err != nil {
+		return errFormat
+	}
 
-	return nil
+	return inserter.Flush()
 }
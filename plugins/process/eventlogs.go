@@ -22,6 +22,7 @@
 package process
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"path"
@@ -45,6 +46,10 @@ func (*EventlogsPlugin) Description() string {
 	return "Parse eventlogs into single events"
 }
 
+func (*EventlogsPlugin) InputTypes() []string {
+	return []string{"file"}
+}
+
 func getString(item gostore.Item, key string) (string, bool) {
 	if name, ok := item[key]; ok {
 		if name, ok := name.(string); ok {
@@ -54,45 +59,69 @@ func getString(item gostore.Item, key string) (string, bool) {
 	return "", false
 }
 
-func (*EventlogsPlugin) Run(url string, data daggy.Arguments, filter daggy.Filter) error {
+func (*EventlogsPlugin) Run(ctx context.Context, url string, data daggy.Arguments, filter daggy.Filter) error {
 	store, err := goforensicstore.NewJSONLite(url)
 	if err != nil {
 		return err
 	}
 
-	fileItems, err := store.Select("file", filter)
+	exportPaths, err := exportPaths(url, filter.And(map[string]string{"name": "suffix:.evtx"}), ".evtx")
 	if err != nil {
 		return err
 	}
 
-	for _, item := range fileItems {
-		if name, ok := getString(item, "name"); ok {
-			if strings.HasSuffix(name, ".evtx") {
-				if exportPath, ok := getString(item, "export_path"); ok {
-					file, err := store.Open(path.Join(url, exportPath))
-					if err != nil {
-						return err
-					}
-
-					err = getEvents(file, store)
-					if err != nil {
-						return err
-					}
-				}
-			}
+	inserter := daggy.NewBatchInserter(ctx, store)
+	for _, exportPath := range exportPaths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		file, err := store.Open(path.Join(url, exportPath))
+		if err != nil {
+			return err
+		}
+
+		err = getEvents(ctx, file, inserter)
+		file.Close()
+		if err != nil {
+			return err
 		}
 	}
 
-	return nil
+	return inserter.Flush()
 }
 
-func getEvents(file io.ReadSeeker, store gostore.Store) error {
+// exportPaths returns the export paths of the selected files with the given
+// extension. The paths are read before the files are processed, so the item
+// database is not locked while new items are inserted.
+func exportPaths(url string, filter daggy.Filter, extension string) ([]string, error) {
+	it, err := daggy.SelectIterator(url, "file", filter)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var exportPaths []string
+	for it.Next() {
+		item := it.Item()
+		if name, ok := getString(item, "name"); ok && strings.HasSuffix(name, extension) {
+			if exportPath, ok := getString(item, "export_path"); ok {
+				exportPaths = append(exportPaths, exportPath)
+			}
+		}
+	}
+	return exportPaths, it.Err()
+}
+
+func getEvents(ctx context.Context, file io.ReadSeeker, inserter *daggy.BatchInserter) error {
 	chunks, err := evtx.GetChunks(file)
 	if err != nil {
 		return err
 	}
 
 	for _, chunk := range chunks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		records, err := chunk.Parse(int(chunk.Header.FirstEventRecID))
 		if err != nil {
 			return err
@@ -120,8 +149,7 @@ func getEvents(file io.ReadSeeker, store gostore.Store) error {
 					return err
 				}
 
-				_, err = store.Insert(item)
-				if err != nil {
+				if err := inserter.Insert(item); err != nil {
 					return err
 				}
 			}
@@ -25,7 +25,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"testing"
 
+	"github.com/forensicanalysis/forensicworkflows/daggy"
 	"github.com/otiai10/copy"
 )
 
@@ -78,3 +80,21 @@ func cleanup(folders ...string) (err error) {
 	}
 	return nil
 }
+
+func TestBuiltinWorkflows_Validate(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "workflows", "*.yml"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no built-in workflows found: %v", err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			workflow, err := daggy.Parse(file)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if err := workflow.Validate(Plugins, filepath.Join("..", "..", "scripts", "process")); err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+		})
+	}
+}
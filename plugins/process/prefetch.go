@@ -22,8 +22,8 @@
 package process
 
 import (
+	"context"
 	"path"
-	"strings"
 	"time"
 
 	"www.velocidex.com/golang/go-prefetch"
@@ -42,60 +42,59 @@ func (*PrefetchPlugin) Description() string {
 	return "Parse prefetch files"
 }
 
-func (*PrefetchPlugin) Run(url string, data daggy.Arguments, filter daggy.Filter) error {
+func (*PrefetchPlugin) InputTypes() []string {
+	return []string{"file"}
+}
+
+func (*PrefetchPlugin) Run(ctx context.Context, url string, data daggy.Arguments, filter daggy.Filter) error {
 	store, err := goforensicstore.NewJSONLite(url)
 	if err != nil {
 		return err
 	}
 
-	fileItems, err := store.Select("file", filter)
+	exportPaths, err := exportPaths(url, filter.And(map[string]string{"name": "suffix:.pf"}), ".pf")
 	if err != nil {
 		return err
 	}
 
-	for _, item := range fileItems {
-		if name, ok := item["name"]; ok {
-			if name, ok := name.(string); ok {
-				if strings.HasSuffix(name, ".pf") {
-					if exportPath, ok := item["export_path"]; ok {
-						if exportPath, ok := exportPath.(string); ok {
-							file, err := store.Open(path.Join(url, exportPath))
-							if err != nil {
-								return err
-							}
+	for _, exportPath := range exportPaths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		file, err := store.Open(path.Join(url, exportPath))
+		if err != nil {
+			return err
+		}
 
-							prefetchInfo, err := prefetch.LoadPrefetch(file)
-							if err != nil {
-								return err
-							}
+		prefetchInfo, err := prefetch.LoadPrefetch(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
 
-							_, err = store.InsertStruct(struct {
-								Executable    string
-								FileSize      uint32
-								Hash          string
-								Version       string
-								LastRunTimes  []time.Time
-								FilesAccessed []string
-								RunCount      uint32
-								Type          string
-							}{
-								prefetchInfo.Executable,
-								prefetchInfo.FileSize,
-								prefetchInfo.Hash,
-								prefetchInfo.Version,
-								prefetchInfo.LastRunTimes,
-								prefetchInfo.FilesAccessed,
-								prefetchInfo.RunCount,
-								"prefetch",
-							})
-							if err != nil {
-								return err
-							}
-						}
-					}
-				}
-			}
+		_, err = store.InsertStruct(struct {
+			Executable    string
+			FileSize      uint32
+			Hash          string
+			Version       string
+			LastRunTimes  []time.Time
+			FilesAccessed []string
+			RunCount      uint32
+			Type          string
+		}{
+			prefetchInfo.Executable,
+			prefetchInfo.FileSize,
+			prefetchInfo.Hash,
+			prefetchInfo.Version,
+			prefetchInfo.LastRunTimes,
+			prefetchInfo.FilesAccessed,
+			prefetchInfo.RunCount,
+			"prefetch",
+		})
+		if err != nil {
+			return err
 		}
+		daggy.CountItems(ctx, 1)
 	}
 
 	return nil
@@ -22,6 +22,7 @@
 package process
 
 import (
+	"context"
 	"log"
 	"path/filepath"
 	"testing"
@@ -59,7 +60,7 @@ func TestPrefetchPlugin_Run(t *testing.T) {
 			pr := &PrefetchPlugin{}
 
 			url := filepath.Join(storeDir, "data", tt.args.storeName)
-			if err := pr.Run(url, tt.args.data, tt.args.filter); (err != nil) != tt.wantErr {
+			if err := pr.Run(context.Background(), url, tt.args.data, tt.args.filter); (err != nil) != tt.wantErr {
 				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
@@ -31,7 +31,7 @@ from datetime import datetime
 
 import forensicstore
 
-from ...util import combined_conditions
+from ...util import select
 
 LOGGER = logging.getLogger(__name__)
 
@@ -147,7 +147,7 @@ def main():
     }, {
         'key': hklmsw + "Microsoft\\Updates\\%\\%"
     }]
-    for item in store.select("windows-registry-key", combined_conditions(conditions)):
+    for item in select(store, "windows-registry-key", conditions):
         results = transform(item)
         for result in results:
             store.insert(result)
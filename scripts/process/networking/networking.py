@@ -22,7 +22,7 @@
 
 import forensicstore
 
-from ...util import combined_conditions
+from ...util import select
 
 NAMES_KEY = r"\control\network\{4d36e972-e325-11ce-bfc1-08002be10318}"
 INTERFACE_KEY = r'\services\tcpip\parameters\interfaces'
@@ -99,7 +99,7 @@ def main():
         {'key': r"HKEY_LOCAL_MACHINE\SYSTEM\%ControlSet%\Control\Network\{4D36E972-E325-11CE-BFC1-08002BE10318}\%"},
         {'key': r"HKEY_LOCAL_MACHINE\SYSTEM\%ControlSet%\Services\Tcpip\Parameters\Interfaces\%"}
     ]
-    items = store.select("windows-registry-key", combined_conditions(conditions))
+    items = select(store, "windows-registry-key", conditions)
     for result in transform(items):
         store.insert(result)
     store.close()
@@ -26,7 +26,7 @@ import sys
 import forensicstore
 import jinja2
 
-from ...util import combined_conditions
+from ...util import select
 
 
 def transform(store, items, template_name):
@@ -47,7 +47,7 @@ def transform(store, items, template_name):
 
 def main():
     store = forensicstore.connect(".")
-    items = list(store.select(sys.argv[1], combined_conditions(None)))
+    items = list(select(store, sys.argv[1], None))
     result = transform(store, items, sys.argv[2])
     if result:
         store.insert(result)
@@ -21,9 +21,11 @@
 # Author(s): Jonas Plum
 
 
+import json
+
 import forensicstore
 
-from ...util import combined_conditions
+from ...util import select
 
 
 def transform(items):
@@ -68,11 +70,13 @@ def main():
         {'key': hkusw + r"Wow6432Node\Microsoft\Windows\CurrentVersion\RunOnce\Setup"},
         {'key': hkusw + r"Wow6432Node\Microsoft\Windows\CurrentVersion\RunOnceEx"}
     ]
-    items = store.select("windows-registry-key", combined_conditions(conditions))
+    items = select(store, "windows-registry-key", conditions)
     results = transform(items)
     for result in results:
         store.insert(result)
     store.close()
+    # the number of run keys is an output of the task
+    print(json.dumps({"count": len(results)}))
 
 
 if __name__ == '__main__':
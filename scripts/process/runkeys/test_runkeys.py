@@ -19,6 +19,7 @@
 #
 # Author(s): Jonas Plum
 
+import json
 import os
 import shutil
 import tempfile
@@ -35,11 +36,12 @@ def data():
     return os.path.join(tmpdir, "data")
 
 
-def test_runkeys(data):
+def test_runkeys(data, capsys):
     cwd = os.getcwd()
     os.chdir(os.path.join(data, "data", "example1.forensicstore"))
 
     main()
+    assert json.loads(capsys.readouterr().out) == {"count": 10}
 
     store = forensicstore.connect(os.path.join(data, "data", "example1.forensicstore"))
     items = list(store.select("runkey"))
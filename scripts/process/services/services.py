@@ -24,7 +24,7 @@
 
 import forensicstore
 
-from ...util import combined_conditions
+from ...util import select
 
 
 def transform(objs):
@@ -122,7 +122,7 @@ def _servicetype_from_bitmask(mask):
 def main():
     store = forensicstore.connect(".")
     conditions = [{'key': "HKEY_LOCAL_MACHINE\\SYSTEM\\%ControlSet%\\Services\\%"}]
-    items = list(store.select("windows-registry-key", combined_conditions(conditions)))
+    items = list(select(store, "windows-registry-key", conditions))
     results = transform(items)
     for result in results:
         store.insert(result)
@@ -32,7 +32,7 @@ from base64 import b64decode
 
 import forensicstore
 
-from ...util import combined_conditions
+from ...util import select
 
 LOGGER = logging.getLogger(__name__)
 
@@ -86,7 +86,7 @@ def main():
         'key':
             "HKEY_LOCAL_MACHINE\\System\\%ControlSet%\\Control\\Session Manager\\AppCompat%"
     }]
-    items = store.select("windows-registry-key", combined_conditions(conditions))
+    items = select(store, "windows-registry-key", conditions)
     for item in items:
         results = transform(item)
         for result in results:
@@ -25,7 +25,7 @@ This plugin parses the Windows Uninstaller registry keys for a list of installed
 
 import forensicstore
 
-from scripts.util import combined_conditions
+from scripts.util import select
 
 
 def transform(obj):
@@ -73,7 +73,7 @@ def main():
         'key': "HKEY_USERS\\%\\Software\\Microsoft\\Windows\\CurrentVersion\\Uninstall\\%"
     }]
 
-    items = list(store.select("windows-registry-key", combined_conditions(conditions)))
+    items = list(select(store, "windows-registry-key", conditions))
     for item in items:
         results = transform(item)
         for result in results:
@@ -22,7 +22,7 @@
 
 import forensicstore
 
-from ...util import combined_conditions
+from ...util import select
 
 
 class USBForensicStoreExtractor:
@@ -45,8 +45,8 @@ class USBForensicStoreExtractor:
 
     def _get_system_usb_data(self):
         system_mounted_usb_info = {}
-        conditions = combined_conditions([{"artifact": "WindowsUSBVolumeAndDriveMapping"}])
-        usb_system_mounted_devices = list(self.forensicstore.select("windows-registry-key", conditions))
+        conditions = [{"artifact": "WindowsUSBVolumeAndDriveMapping"}]
+        usb_system_mounted_devices = list(select(self.forensicstore, "windows-registry-key", conditions))
         if not usb_system_mounted_devices:
             return {}
         usb_system_mounted_devices = [device.get('values') for device in usb_system_mounted_devices].pop()
@@ -74,8 +74,8 @@ class USBForensicStoreExtractor:
         return system_mounted_usb_info
 
     def _get_user_usb_data(self, system_mounted_usb_info: dict):
-        conditions = combined_conditions([{"artifact": "WindowsUSBUserMountedDevices"}])
-        usb_user_mounted_data = self.forensicstore.select("windows-registry-key", conditions)
+        conditions = [{"artifact": "WindowsUSBUserMountedDevices"}]
+        usb_user_mounted_data = select(self.forensicstore, "windows-registry-key", conditions)
 
         # Categorises found system usb usage to user usb usage.
         usb_user_mounted_devices = []
@@ -92,8 +92,8 @@ class USBForensicStoreExtractor:
         return usb_user_mounted_devices
 
     def _get_all_usb_data(self, usb_user_mounted_devices: list):
-        conditions = combined_conditions([{"artifact": "WindowsUSBDeviceInformations"}])
-        usb_device_information = self.forensicstore.select("windows-registry-key", conditions)
+        conditions = [{"artifact": "WindowsUSBDeviceInformations"}]
+        usb_device_information = select(self.forensicstore, "windows-registry-key", conditions)
 
         # Combines the gathered information to create a dictionary of actual used usb devices and some meta data.
         # Also keeps track about non mounted usb devices.
@@ -20,7 +20,18 @@
 # Author(s): Jonas Plum
 
 import argparse
+import math
+import re
 import sys
+from datetime import datetime, timezone
+
+OPERATOR = re.compile(r'^(eq|prefix|suffix|contains|regex|gt|ge|lt|le|in):')
+SIZE = re.compile(r'^(-?[0-9]*\.?[0-9]+)\s*([a-zA-Z]*)$')
+SIZE_UNITS = {
+    "": 1, "b": 1,
+    "kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12,
+    "kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40,
+}
 
 
 def merge_conditions(list_a, list_b):
@@ -35,14 +46,36 @@ def merge_conditions(list_a, list_b):
     return list_c
 
 
+def parse_filter(text):
+    """ Parse a filter like "type=file,name=suffix:.evtx" where commas, equal signs
+    and backslashes can be escaped by a backslash. """
+    conditions = {}
+    elements = [[""]]
+    escaped = False
+    for char in text:
+        if escaped:
+            elements[-1][-1] += char
+            escaped = False
+        elif char == "\\":
+            escaped = True
+        elif char == ",":
+            elements.append([""])
+        elif char == "=" and len(elements[-1]) == 1:
+            elements[-1].append("")
+        else:
+            elements[-1][-1] += char
+    for element in elements:
+        if len(element) != 2:
+            raise ValueError("invalid filter element %s" % "".join(element))
+        conditions[element[0]] = element[1]
+    return conditions
+
+
 class StoreDictKeyPair(argparse.Action):
     # pylint: disable=too-few-public-methods
 
     def __call__(self, parser, namespace, values, option_string=None):
-        new_dict = {}
-        for element in values.split(","):
-            key, value = element.split("=")
-            new_dict[key] = value
+        new_dict = parse_filter(values)
         if hasattr(namespace, self.dest):
             dict_list = getattr(namespace, self.dest)
             if dict_list is not None:
@@ -52,9 +85,145 @@ class StoreDictKeyPair(argparse.Action):
         setattr(namespace, self.dest, [new_dict])
 
 
+def parse_number(value):
+    match = SIZE.match(str(value).strip())
+    if not match or match.group(2).lower() not in SIZE_UNITS:
+        return None
+    return float(match.group(1)) * SIZE_UNITS[match.group(2).lower()]
+
+
+def parse_time(value):
+    try:
+        parsed = datetime.fromisoformat(re.sub(r'Z$', '+00:00', str(value)))
+    except ValueError:
+        return None
+    if parsed.tzinfo is None:
+        parsed = parsed.replace(tzinfo=timezone.utc)
+    return parsed
+
+
+def string_value(value):
+    if value is None:
+        return ""
+    if isinstance(value, bool):
+        return "true" if value else "false"
+    if isinstance(value, float) and math.isfinite(value) and value.is_integer():
+        return str(int(value))
+    return str(value)
+
+
+def number_value(value):
+    if isinstance(value, bool):
+        return None
+    if isinstance(value, (int, float)):
+        return float(value)
+    if isinstance(value, str):
+        return parse_number(value)
+    return None
+
+
+def lookup(item, attribute):
+    """ Return the value of a dotted attribute, e.g. attributes.created. """
+    if attribute in item:
+        return True, item[attribute]
+    parts = attribute.split(".", 1)
+    if len(parts) == 2 and isinstance(item.get(parts[0]), dict):
+        return lookup(item[parts[0]], parts[1])
+    return False, None
+
+
+class Condition:
+    """ A filter expression for a single attribute with the same semantics as
+    the Filter of forensicworkflows. Values without operator are LIKE patterns. """
+    # pylint: disable=too-few-public-methods
+
+    def __init__(self, attribute, expression):
+        self.attribute = attribute
+        self.negate = expression.startswith("!")
+        value = expression[1:] if self.negate else expression
+        self.operator = ""
+        match = OPERATOR.match(value)
+        if match:
+            self.operator = match.group(1)
+            value = value[len(match.group(0)):]
+        elif self.negate:
+            self.operator = "contains"
+        self.value = value
+
+        if self.operator == "regex":
+            self.regex = re.compile(value)
+        elif self.operator in ("gt", "ge", "lt", "le"):
+            self.number = parse_number(value)
+            self.time = None if self.number is not None else parse_time(value)
+            if self.number is None and self.time is None:
+                raise ValueError("%s for %s needs a number, size or time, got `%s`" %
+                                 (self.operator, attribute, value))
+        elif self.operator == "":
+            pattern = "".join(".*" if c == "%" else "." if c == "_" else re.escape(c) for c in value)
+            self.regex = re.compile(pattern, re.IGNORECASE | re.DOTALL)
+
+    def match(self, item):
+        found, value = lookup(item, self.attribute)
+        if self.operator == "":
+            return found and self.regex.fullmatch(string_value(value)) is not None
+        if not found or value is None:
+            return self.negate
+        return self._compare(value) != self.negate
+
+    def _compare(self, value):
+        text = string_value(value)
+        comparisons = {
+            "eq": lambda: text == self.value,
+            "prefix": lambda: text.startswith(self.value),
+            "suffix": lambda: text.endswith(self.value),
+            "contains": lambda: self.value in text,
+            "regex": lambda: self.regex.search(text) is not None,
+            "in": lambda: text in self.value.split("|"),
+        }
+        if self.operator in comparisons:
+            return comparisons[self.operator]()
+
+        if self.number is not None:
+            left, right = number_value(value), self.number
+        else:
+            left, right = parse_time(text), self.time
+        if left is None:
+            return False
+        return {
+            "gt": left > right,
+            "ge": left >= right,
+            "lt": left < right,
+            "le": left <= right,
+        }[self.operator]
+
+
+def match(item, conditions):
+    """ Test if an item matches any of the conditions. """
+    if conditions is None:
+        return True
+    return any(all(Condition(k, v).match(item) for k, v in condition.items()) for condition in conditions)
+
+
 def combined_conditions(conditions):
     parser = argparse.ArgumentParser(description='parse key pairs into a dictionary')
     parser.add_argument("--filter", dest="filter", action=StoreDictKeyPair, metavar="type=file,name=System.evtx...")
     args, _ = parser.parse_known_args(sys.argv[1:])
 
     return merge_conditions(args.filter, conditions)
+
+
+def select(store, item_type, conditions=None):
+    """ Select the items of a type that match the conditions and the --filter
+    argument. Values without operator are passed to the store as LIKE patterns,
+    operators are checked afterwards. """
+    combined = combined_conditions(conditions)
+    like_conditions = None
+    if combined is not None:
+        like_conditions = []
+        for condition in combined:
+            like_condition = {k: v for k, v in condition.items() if Condition(k, v).operator == "" and k != "type"}
+            if not like_condition:
+                like_conditions = None
+                break
+            like_conditions.append(like_condition)
+    return [item for item in store.select(item_type, like_conditions) if match(item, combined)]
@@ -19,7 +19,7 @@
 #
 # Author(s): Jonas Plum
 
-from .util import merge_conditions
+from .util import Condition, match, merge_conditions, parse_filter
 
 
 def test_merge_1():
@@ -48,3 +48,43 @@ def test_merge_1():
     }]
 
     assert result == expected
+
+
+def test_parse_filter():
+    assert parse_filter("type=file,name=System.evtx") == {"type": "file", "name": "System.evtx"}
+    assert parse_filter("key=HKLM\\\\Software\\,x,value=in:a|b") == {"key": "HKLM\\Software,x", "value": "in:a|b"}
+    assert parse_filter("a\\=b=c=d") == {"a=b": "c=d"}
+
+
+def test_condition():
+    item = {
+        "name": "C:\\Windows\\System32\\winevt\\Logs\\System.evtx",
+        "size": 2 * 1024 * 1024,
+        "attributes": {"created": "2020-02-03T04:05:06Z"},
+    }
+    tests = [
+        ("name", "%system.evtx", True),
+        ("name", "eq:System.evtx", False),
+        ("name", "suffix:.evtx", True),
+        ("name", "prefix:C:", True),
+        ("name", "!contains:System32", False),
+        ("name", "!System32", False),
+        ("name", "regex:Logs\\\\[A-Z]", True),
+        ("size", "gt:1MB", True),
+        ("size", "le:2MiB", True),
+        ("size", "lt:2MiB", False),
+        ("attributes.created", "ge:2020-02-03", True),
+        ("attributes.created", "lt:2020-01-01T00:00:00Z", False),
+        ("missing", "eq:x", False),
+        ("missing", "!eq:x", True),
+        ("size", "in:1|2097152", True),
+    ]
+    for attribute, expression, expected in tests:
+        assert Condition(attribute, expression).match(item) == expected, (attribute, expression)
+
+
+def test_match():
+    item = {"type": "file", "name": "a.pf"}
+    assert match(item, None)
+    assert match(item, [{"name": "suffix:.evtx"}, {"name": "suffix:.pf"}])
+    assert not match(item, [{"name": "suffix:.pf", "type": "directory"}])
@@ -58,3 +58,17 @@ teardown() {
   [ "$status" -eq 0 ]
   [ -f "$TESTDIR/export.json" ]
 }
+
+@test "list workflows" {
+  run forensicworkflows workflows list
+  echo $output
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "windows-triage:" ]]
+}
+
+@test "graph builtin workflow" {
+  run forensicworkflows graph --workflow builtin:windows-triage
+  echo $output
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "prefetch" ]]
+}